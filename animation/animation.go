@@ -0,0 +1,141 @@
+// Package animation contains animated GIF logic.
+package animation
+
+import (
+	"image"
+	"image/color"
+	"image/gif"
+	"io"
+	"time"
+)
+
+// Frame contains one animation frame.
+type Frame struct {
+	Board   []string
+	Hands   []string
+	Caption []string
+}
+
+const (
+	margin      = 8
+	squareSize  = 32
+	pieceInset  = 4
+	pieceScale  = 3
+	textScale   = 2
+	textAdvance = (glyphWidth + 1) * textScale
+	lineHeight  = (glyphHeight + 2) * textScale
+)
+
+const (
+	indexBackground = iota
+	indexBlack
+	indexWhite
+	indexLight
+	indexDark
+	indexText
+)
+
+var palette = color.Palette{
+	color.RGBA{0xf8, 0xf8, 0xf8, 0xff},
+	color.RGBA{0x10, 0x10, 0x10, 0xff},
+	color.RGBA{0xff, 0xff, 0xff, 0xff},
+	color.RGBA{0xf0, 0xd9, 0xb5, 0xff},
+	color.RGBA{0xb5, 0x88, 0x63, 0xff},
+	color.RGBA{0x40, 0x40, 0x40, 0xff},
+}
+
+// Write writes the frames as an animated GIF.
+func Write(writer io.Writer, frames []Frame, delay time.Duration) error {
+	res := &gif.GIF{}
+	bounds := size(frames)
+	for _, frame := range frames {
+		res.Image = append(res.Image, draw(frame, bounds))
+		res.Delay = append(res.Delay, int(delay/(10*time.Millisecond)))
+	}
+	return gif.EncodeAll(writer, res)
+}
+
+func size(frames []Frame) image.Rectangle {
+	width, height := 0, 0
+	for _, frame := range frames {
+		lines := append(append([]string{}, frame.Hands...), frame.Caption...)
+		frameWidth := 0
+		if len(frame.Board) > 0 {
+			frameWidth = len(frame.Board[0]) * squareSize
+		}
+		for _, line := range lines {
+			frameWidth = max(frameWidth, len(line)*textAdvance)
+		}
+		width = max(width, frameWidth)
+		height = max(height, len(frame.Board)*squareSize+len(lines)*lineHeight)
+	}
+	return image.Rect(0, 0, width+2*margin, height+3*margin)
+}
+
+func draw(frame Frame, bounds image.Rectangle) *image.Paletted {
+	img := image.NewPaletted(bounds, palette)
+	fill(img, bounds, indexBackground)
+	for i, row := range frame.Board {
+		for j := range len(row) {
+			x := margin + j*squareSize
+			y := margin + i*squareSize
+			square := image.Rect(x, y, x+squareSize, y+squareSize)
+			index := uint8(indexLight)
+			if (i+j)%2 == 1 {
+				index = indexDark
+			}
+			fill(img, square, index)
+			piece := row[j]
+			switch piece {
+			case ' ':
+				continue
+			case '#':
+				fill(img, square, indexBlack)
+				continue
+			case '.':
+				fill(img, square.Inset(pieceInset), indexBackground)
+				continue
+			}
+			background, foreground := uint8(indexWhite), uint8(indexBlack)
+			if piece >= 'a' && piece <= 'z' {
+				background, foreground = indexBlack, indexWhite
+			}
+			fill(img, square.Inset(pieceInset), background)
+			text(img, string(piece),
+				x+(squareSize-glyphWidth*pieceScale)/2, y+(squareSize-glyphHeight*pieceScale)/2,
+				pieceScale, foreground)
+		}
+	}
+	y := 2*margin + len(frame.Board)*squareSize
+	for _, line := range append(append([]string{}, frame.Hands...), frame.Caption...) {
+		text(img, line, margin, y, textScale, indexText)
+		y += lineHeight
+	}
+	return img
+}
+
+func fill(img *image.Paletted, rect image.Rectangle, index uint8) {
+	for y := rect.Min.Y; y < rect.Max.Y; y++ {
+		for x := rect.Min.X; x < rect.Max.X; x++ {
+			img.SetColorIndex(x, y, index)
+		}
+	}
+}
+
+func text(img *image.Paletted, line string, x, y, scale int, index uint8) {
+	for _, r := range line {
+		glyph, ok := glyphs[r]
+		if !ok {
+			glyph = glyphs['?']
+		}
+		for gy, row := range glyph {
+			for gx, v := range row {
+				if v != '#' {
+					continue
+				}
+				fill(img, image.Rect(x+gx*scale, y+gy*scale, x+(gx+1)*scale, y+(gy+1)*scale), index)
+			}
+		}
+		x += (glyphWidth + 1) * scale
+	}
+}
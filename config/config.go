@@ -1,15 +1,71 @@
 // Package config contains configuration.
 package config
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// Outcome contains a game outcome for the side to move.
+type Outcome int
+
+// Outcomes.
+const (
+	Draw Outcome = iota
+	Win
+	Loss
+)
+
+var outcomes = []string{"draw", "win", "loss"}
+
+// ParseOutcome parses an outcome name.
+func ParseOutcome(name string) (Outcome, error) {
+	for i, one := range outcomes {
+		if one == name {
+			return Outcome(i), nil
+		}
+	}
+	return Draw, fmt.Errorf("outcome %q must be one of %v", name, outcomes)
+}
+
+// String returns the outcome name.
+func (o Outcome) String() string {
+	return outcomes[o]
+}
+
+// Value returns the outcome value for the side to move.
+func (o Outcome) Value() int {
+	return []int{0, 1, -1}[o]
+}
 
 // Config contains configuration.
 type Config struct {
-	SleepDuration   time.Duration
-	Board           string
-	MaxPrintDepth   int
-	EnableShow      bool
-	PrintDepth      bool
-	EnablePromotion bool
-	EnableDrop      bool
+	SleepDuration        time.Duration
+	GIFDelay             time.Duration
+	CheckpointInterval   time.Duration
+	Board                string
+	RulesFile            string
+	TableDir             string
+	CheckpointFile       string
+	MoveOrder            string
+	PuzzleMaterial       string
+	PuzzleHands          string
+	Width                int
+	Height               int
+	MaxPrintDepth        int
+	DOTMaxDepth          int
+	PuzzleMoves          int
+	ExplainMaxDepth      int
+	TableSize            int
+	StaleMate            Outcome
+	EnableShow           bool
+	PrintDepth           bool
+	EnablePromotion      bool
+	EnableDrop           bool
+	EnableQueenPromotion bool
+	EnableDemotion       bool
+	EnableCheckmate      bool
+	EnableBlockedKnight  bool
+	DOTBestOnly          bool
+	PuzzleUnique         bool
 }
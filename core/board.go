@@ -0,0 +1,332 @@
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+const (
+	maxHeight    = 6
+	maxWidth     = 6
+	maxHand      = 6
+	maxHandCount = 255
+	defaultSize  = 4
+)
+
+// Board contains the board rows.
+type Board [maxHeight][maxWidth]byte
+
+// Hands contains the number of pieces of each kind in each hand.
+type Hands [2][maxHand]uint8
+
+// Position contains the board, the hands and the promoted squares.
+type Position struct {
+	Board    Board
+	Hands    Hands
+	Promoted uint64
+}
+
+const defaultBoard = "bnrk,   p,P   ,KRNB"
+
+func (c *Core) parse(board string) error {
+	rows := []string{}
+	if len(board) > 1 {
+		rows = strings.Split(board, ",")
+	}
+	promoted := ""
+	if len(rows) > 0 && strings.HasPrefix(rows[len(rows)-1], "+") {
+		promoted, rows = rows[len(rows)-1], rows[:len(rows)-1]
+	}
+	hands := []string{}
+	for len(rows) > 0 && len(hands) < 2 && isHand(rows[len(rows)-1]) {
+		hands = append([]string{rows[len(rows)-1]}, hands...)
+		rows = rows[:len(rows)-1]
+	}
+	c.height, c.width = c.config.Height, c.config.Width
+	if len(rows) == 0 {
+		c.height, c.width = max(c.height, defaultSize), max(c.width, defaultSize)
+		if c.height == defaultSize && c.width == defaultSize {
+			rows = strings.Split(defaultBoard, ",")
+		}
+		for len(rows) < c.height {
+			rows = append(rows, strings.Repeat(" ", c.width))
+		}
+	}
+	if c.height == 0 {
+		c.height = len(rows)
+	}
+	if c.width == 0 {
+		c.width = len(rows[0])
+	}
+	if c.height < 1 || c.height > maxHeight || c.width < 1 || c.width > maxWidth {
+		return fmt.Errorf("board size %dx%d must be between 1x1 and %dx%d", c.width, c.height, maxWidth, maxHeight)
+	}
+	if len(rows) != c.height {
+		return fmt.Errorf("board has %d rows, want %d", len(rows), c.height)
+	}
+	for i := range c.board {
+		for j := range c.board[i] {
+			c.board[i][j] = ' '
+		}
+	}
+	for i, row := range rows {
+		if len(row) != c.width {
+			return fmt.Errorf("board row %q has %d columns, want %d", row, len(row), c.width)
+		}
+		for j := range len(row) {
+			if _, ok := c.colors[row[j]]; !ok {
+				return fmt.Errorf("board row %q has invalid piece %q", row, row[j])
+			}
+			c.board[i][j] = row[j]
+		}
+	}
+	if err := c.parsePromoted(promoted); err != nil {
+		return err
+	}
+	c.hands = Hands{}
+	given := 0
+	for i, hand := range hands {
+		counts, err := c.parseHand(hand, i)
+		if err != nil {
+			return err
+		}
+		copy(c.hands[i][:], counts)
+		given = max(given, len(counts))
+	}
+	c.handSize = max(given, c.handKinds())
+	return c.validate()
+}
+
+// handKinds returns the number of hand kinds up to the last one that can be
+// in a hand, which is on the board, in a hand, a promotion of one of them or
+// the demotion of a promoted square.
+func (c *Core) handKinds() int {
+	kinds := map[byte]bool{}
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.colors[c.board[i][j]] >= 0 {
+				kinds[color(c.board[i][j], 0)] = true
+			}
+		}
+	}
+	for turn, hand := range c.hands {
+		for i, count := range hand[:len(c.deadXY[turn])] {
+			if count > 0 {
+				kinds[color(c.deadXY[turn][i], 0)] = true
+			}
+		}
+	}
+	promotions := []byte{}
+	for letter := range kinds {
+		promotions = append(promotions, c.promos[letter]...)
+	}
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.promoted&promotedBit(i, j) != 0 {
+				promotions = append(promotions, color(c.undoPromos[c.board[i][j]], 0))
+			}
+		}
+	}
+	for _, letter := range promotions {
+		kinds[letter] = true
+	}
+	res := 0
+	for i, letter := range c.deadXY[0] {
+		if kinds[letter] {
+			res = i + 1
+		}
+	}
+	return res
+}
+
+func (c *Core) parseHand(hand string, turn int) ([]uint8, error) {
+	fields := strings.Split(hand, "")
+	if strings.Contains(hand, "/") {
+		fields = strings.Split(hand, "/")
+	}
+	if len(fields) > len(c.deadXY[turn]) {
+		return nil, fmt.Errorf("hand %q has %d counts, want at most %d", hand, len(fields), len(c.deadXY[turn]))
+	}
+	res := []uint8{}
+	for _, field := range fields {
+		count, err := strconv.Atoi(field)
+		if err != nil || count < 0 || count > maxHandCount {
+			return nil, fmt.Errorf("hand %q has invalid count %q", hand, field)
+		}
+		res = append(res, uint8(count))
+	}
+	return res, nil
+}
+
+// parsePromoted parses the promoted squares, such as "+10+23" for the
+// squares 10 and 23, which only matter with --enable_demotion.
+func (c *Core) parsePromoted(promoted string) error {
+	c.promoted = 0
+	if promoted == "" {
+		return nil
+	}
+	if !c.config.EnableDemotion {
+		return fmt.Errorf("promoted squares %q need --enable_demotion", promoted)
+	}
+	for _, square := range strings.Split(promoted[1:], "+") {
+		if len(square) != 2 || square[0] < '0' || square[0] > '9' || square[1] < '0' || square[1] > '9' {
+			return fmt.Errorf("promoted squares %q have invalid square %q", promoted, square)
+		}
+		i, j := int(square[0]-'0'), int(square[1]-'0')
+		if i >= c.height || j >= c.width {
+			return fmt.Errorf("promoted square %q is outside the board", square)
+		}
+		if _, ok := c.undoPromos[c.board[i][j]]; !ok {
+			return fmt.Errorf("promoted square %q has %q, which is not a promotion", square, c.board[i][j])
+		}
+		if c.promoted&promotedBit(i, j) != 0 {
+			return fmt.Errorf("promoted square %q is repeated", square)
+		}
+		c.promoted |= promotedBit(i, j)
+	}
+	return nil
+}
+
+func (c *Core) validate() error {
+	pieces := 0
+	squares := 0
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			switch c.board[i][j] {
+			case wall, hole:
+			case ' ':
+				squares++
+			default:
+				squares++
+				pieces++
+			}
+		}
+	}
+	for turn, hand := range c.hands {
+		for i, count := range hand[:len(c.deadXY[turn])] {
+			pieces += int(count)
+			if count == 0 {
+				continue
+			}
+			piece := c.deadXY[turn][i]
+			if limit := c.standSquares(piece, squares); int(count) > limit {
+				return fmt.Errorf("hand has %d %c, more than the %d squares it can stand on", count, piece, limit)
+			}
+		}
+	}
+	if pieces > squares {
+		return fmt.Errorf("board and hands have %d pieces, more than the %d squares", pieces, squares)
+	}
+	return nil
+}
+
+// standSquares returns the number of the squares where piece can stand, which
+// excludes its promotion rank with --enable_promotion.
+func (c *Core) standSquares(piece byte, squares int) int {
+	if !c.config.EnablePromotion || len(c.promos[piece]) == 0 {
+		return squares
+	}
+	i := c.promotionRank(piece)
+	for j := 0; j < c.width; j++ {
+		if c.board[i][j] != wall && c.board[i][j] != hole {
+			squares--
+		}
+	}
+	return squares
+}
+
+func isHand(row string) bool {
+	for _, v := range []byte(row) {
+		if (v < '0' || v > '9') && v != '/' {
+			return false
+		}
+	}
+	return len(row) > 0
+}
+
+func (c *Core) rows() []string {
+	res := []string{}
+	for _, row := range c.board[:c.height] {
+		res = append(res, string(row[:c.width]))
+	}
+	for turn := range c.deadXY {
+		if c.handSize > 0 {
+			res = append(res, c.handRow(turn, c.handSize))
+		}
+	}
+	if c.promoted != 0 {
+		res = append(res, c.promotedRow())
+	}
+	return res
+}
+
+func (c *Core) promotedRow() string {
+	res := ""
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.promoted&promotedBit(i, j) != 0 {
+				res += fmt.Sprintf("+%d%d", i, j)
+			}
+		}
+	}
+	return res
+}
+
+func (c *Core) handRow(turn, size int) string {
+	counts := []string{}
+	separator := ""
+	for _, count := range c.hands[turn][:size] {
+		counts = append(counts, strconv.Itoa(int(count)))
+		if count > 9 {
+			separator = "/"
+		}
+	}
+	return strings.Join(counts, separator)
+}
+
+func (c *Core) addHand(turn, index, delta int) {
+	count := int(c.hands[turn][index]) + delta
+	if count < 0 || count > maxHandCount {
+		panic(fmt.Sprintf("hand %c count %d out of range", c.deadXY[turn][index], count))
+	}
+	c.hands[turn][index] = uint8(count)
+}
+
+func (c *Core) key() Position {
+	return Position{Board: c.board, Hands: c.hands, Promoted: c.promoted}
+}
+
+func (c *Core) setKey(position Position) {
+	c.board, c.hands, c.promoted = position.Board, position.Hands, position.Promoted
+}
+
+func promotedBit(i, j int) uint64 {
+	return 1 << (i*maxWidth + j)
+}
+
+func (c *Core) movePromoted(move move.Move) {
+	if !c.config.EnableDemotion {
+		return
+	}
+	to := promotedBit(move.ToX(), move.ToY())
+	promoted := move.Promotion() != 0
+	if !move.IsDrop() {
+		from := promotedBit(move.FromX(), move.FromY())
+		promoted = promoted || c.promoted&from != 0
+		c.promoted &^= from
+	}
+	c.promoted &^= to
+	if promoted {
+		c.promoted |= to
+	}
+}
+
+func (c *Core) handPiece(what byte, promoted bool) byte {
+	if promoted && c.config.EnableDemotion {
+		return c.undoPromos[what]
+	}
+	return what
+}
@@ -0,0 +1,76 @@
+package core
+
+import (
+	"github.com/kssilveira/chess-solver/move"
+	"github.com/kssilveira/chess-solver/printconfig"
+)
+
+func (c *Core) legal(moves *[]move.Move, turn int) {
+	res := (*moves)[:0]
+	for _, move := range *moves {
+		promoted := c.promoted
+		what := c.applyMove(move)
+		check := c.inCheck(turn)
+		c.undoMove(move, what, promoted)
+		if !check {
+			res = append(res, move)
+		}
+	}
+	*moves = res
+}
+
+func (c *Core) inCheck(turn int) bool {
+	nextTurn := (turn + 1) % 2
+	c.checkMoves = c.checkMoves[:0]
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.colors[c.board[i][j]] == nextTurn {
+				c.deltas(&c.checkMoves, nextTurn, i, j)
+			}
+		}
+	}
+	for _, move := range c.checkMoves {
+		if move.IsKing() {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *Core) checkMate(moves, depth, turn int) (int, bool) {
+	if moves != 0 || !c.config.EnableCheckmate || !c.inCheck(turn) {
+		return 0, false
+	}
+	res := -1
+	c.print("checkmate", res, depth, turn, printconfig.PrintConfig{})
+	return res, true
+}
+
+func (c *Core) termination() string {
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	visited := []map[Position]bool{{}, {}}
+	moves := []move.Move{}
+	for turn := 0; ; turn = (turn + 1) % 2 {
+		if visited[turn][c.key()] {
+			return "repetition"
+		}
+		visited[turn][c.key()] = true
+		moves = moves[:0]
+		c.moves(&moves, turn)
+		if len(moves) == 0 {
+			if c.config.EnableCheckmate && c.inCheck(turn) {
+				return "checkmate"
+			}
+			return "stalemate (" + c.config.StaleMate.String() + ")"
+		}
+		move := c.memo((turn + 1) % 2).Move
+		if move == 0 {
+			return "unknown"
+		}
+		if move.IsKing() {
+			return "king capture"
+		}
+		c.applyMove(move)
+	}
+}
@@ -0,0 +1,208 @@
+package core
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"time"
+
+	"github.com/kssilveira/chess-solver/config"
+	"github.com/kssilveira/chess-solver/move"
+)
+
+const (
+	checkpointSteps = 1 << 10
+	checkpointChunk = 1 << 12
+)
+
+// game contains what the values and the search of a checkpoint depend on.
+type game struct {
+	Root                 string
+	Rules                string
+	MoveOrder            string
+	EnablePromotion      bool
+	EnableDrop           bool
+	EnableQueenPromotion bool
+	EnableDemotion       bool
+	EnableCheckmate      bool
+	StaleMate            config.Outcome
+}
+
+// checkpoint contains the search state, followed in the file by chunks of
+// memos up to an empty one.
+type checkpoint struct {
+	Game     game
+	Stack    []State
+	Moves    []move.Move
+	Path     [2][]Position
+	Position Position
+	Nodes    int
+	Repeated int
+	Found    int
+	Decisive []uint64
+	Evicted  int
+	Passes   int
+	MaxDepth int
+	History  [2]map[move.Move]int
+	Killers  [][2]move.Move
+}
+
+// checkpointMemo contains a memo of a checkpoint with the depth it was
+// solved at.
+type checkpointMemo struct {
+	Turn     int
+	Position Position
+	Memo     Memo
+	Depth    int
+}
+
+func (c *Core) game() game {
+	// The rules only contain strings, numbers, booleans and slices, which
+	// always marshal.
+	rules, _ := json.Marshal(c.rules)
+	moveOrder := c.config.MoveOrder
+	if moveOrder == "" {
+		moveOrder = "static"
+	}
+	return game{
+		Root: c.root, Rules: string(rules), MoveOrder: moveOrder,
+		EnablePromotion: c.config.EnablePromotion, EnableDrop: c.config.EnableDrop,
+		EnableQueenPromotion: c.config.EnableQueenPromotion, EnableDemotion: c.config.EnableDemotion,
+		EnableCheckmate: c.config.EnableCheckmate, StaleMate: c.config.StaleMate,
+	}
+}
+
+// checkpoint saves the search to --checkpoint every --checkpoint_interval.
+func (c *Core) checkpoint() {
+	if c.config.CheckpointFile == "" || time.Since(c.saved) < c.config.CheckpointInterval {
+		return
+	}
+	if err := c.saveCheckpoint(c.config.CheckpointFile); err != nil {
+		fmt.Fprintf(c.writer, "\ncheckpoint: %v\n", err)
+	}
+	c.saved = time.Now()
+}
+
+// saveCheckpoint writes the checkpoint to a temporary file first, so that a
+// solve killed while saving keeps the previous checkpoint.
+func (c *Core) saveCheckpoint(name string) error {
+	file, err := os.Create(name + ".tmp")
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if err := c.writeCheckpoint(writer); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), name)
+}
+
+// writeCheckpoint writes the search state, the board and the memos.
+func (c *Core) writeCheckpoint(writer io.Writer) error {
+	encoder := gob.NewEncoder(writer)
+	state := checkpoint{
+		Game: c.game(), Stack: c.stack, Moves: c.arena, Position: c.key(),
+		Nodes: c.nodes, Repeated: c.repeated, Found: c.found, Evicted: c.evicted + c.table.Evicted(),
+		Passes: c.passes, MaxDepth: c.maxDepth,
+	}
+	for key := range c.decisive {
+		state.Decisive = append(state.Decisive, key)
+	}
+	// The orderers learn from the search, so their tables are saved to order
+	// the moves of the resumed search the same way.
+	switch orderer := c.orderer.(type) {
+	case *historyOrder:
+		state.History = orderer.scores
+	case *killerOrder:
+		state.Killers = orderer.killers
+	}
+	for turn, path := range c.path {
+		for position := range path {
+			state.Path[turn] = append(state.Path[turn], position)
+		}
+	}
+	if err := encoder.Encode(state); err != nil {
+		return err
+	}
+	chunk := make([]checkpointMemo, 0, checkpointChunk)
+	for entry := range c.table.All() {
+		chunk = append(chunk, checkpointMemo{
+			Turn: int(entry.turn), Position: entry.position, Memo: entry.memo, Depth: int(entry.depth)})
+		if len(chunk) < checkpointChunk {
+			continue
+		}
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+	}
+	if len(chunk) > 0 {
+		if err := encoder.Encode(chunk); err != nil {
+			return err
+		}
+	}
+	return encoder.Encode([]checkpointMemo{})
+}
+
+// Resume reads a checkpoint written with the same rules and board, so that
+// Solve continues the search where it stopped.
+func (c *Core) Resume(reader io.Reader) error {
+	decoder := gob.NewDecoder(bufio.NewReader(reader))
+	state := checkpoint{}
+	if err := decoder.Decode(&state); err != nil {
+		return fmt.Errorf("checkpoint: %w", err)
+	}
+	if state.Game.Root != c.root {
+		return fmt.Errorf("checkpoint board %q does not match %q", state.Game.Root, c.root)
+	}
+	if state.Game != c.game() {
+		return fmt.Errorf("checkpoint rules do not match the rules of board %q", c.root)
+	}
+	for {
+		chunk := []checkpointMemo{}
+		if err := decoder.Decode(&chunk); err != nil {
+			return fmt.Errorf("checkpoint: %w", err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+		for _, one := range chunk {
+			c.table.Put(one.Turn, one.Position, one.Memo, one.Depth)
+		}
+	}
+	for turn, path := range state.Path {
+		for _, position := range path {
+			c.path[turn][position] = true
+		}
+	}
+	c.stack, c.arena = state.Stack, state.Moves
+	switch orderer := c.orderer.(type) {
+	case *historyOrder:
+		for turn, scores := range state.History {
+			maps.Copy(orderer.scores[turn], scores)
+		}
+	case *killerOrder:
+		orderer.killers = state.Killers
+	}
+	c.setKey(state.Position)
+	for _, key := range state.Decisive {
+		if c.decisive != nil {
+			c.decisive[key] = true
+		}
+	}
+	c.nodes, c.repeated, c.found, c.evicted = state.Nodes, state.Repeated, state.Found, state.Evicted
+	c.passes, c.maxDepth = state.Passes, state.MaxDepth
+	return nil
+}
@@ -5,14 +5,15 @@ import (
 	"bytes"
 	"fmt"
 	"io"
-	"slices"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/kssilveira/chess-solver/animation"
 	"github.com/kssilveira/chess-solver/config"
 	"github.com/kssilveira/chess-solver/move"
 	"github.com/kssilveira/chess-solver/printconfig"
+	"github.com/kssilveira/chess-solver/rules"
 )
 
 // Memo contains the memoized state.
@@ -26,164 +27,180 @@ type Core struct {
 	config        config.Config
 	clearTerminal string
 	writer        io.Writer
-	board         [6][4]byte
-	memo          []map[[6][4]byte]Memo
+	board         Board
+	hands         Hands
+	handSize      int
+	promoted      uint64
+	width         int
+	height        int
+	table         Table
+	path          [2]map[Position]bool
 	sharedMoves   []move.Move
+	checkMoves    []move.Move
+	frames        []animation.Frame
+	orderer       MoveOrderer
+	nodes         int
+	repeated      int
+	found         int
+	decisive      map[uint64]bool
+	evicted       int
+	passes        int
+	maxDepth      int
+	stack         []State
+	arena         []move.Move
+	root          string
+	rules         rules.Rules
+	saved         time.Time
+	space         *space
+	tablebase     map[node]entry
+	pieces
 }
 
-const (
-	deltaDefault = iota
-	deltaEmpty
-	deltaEnemy
-	deltaOtherEmpty
-)
-
-var (
-	colors = map[byte]int{
-		' ': -1,
-		'P': 0, 'K': 0, 'R': 0, 'N': 0, 'B': 0, 'X': 0,
-		'p': 1, 'k': 1, 'r': 1, 'n': 1, 'b': 1, 'x': 1,
-	}
-	deltas = map[byte][][]int{
-		'P': {{-1, 0, deltaEmpty}, {-1, -1, deltaEnemy}, {-1, 1, deltaEnemy}},
-		'R': {{-1, 0}, {1, 0}, {0, -1}, {0, 1}},
-		'B': {{-1, -1}, {1, 1}, {1, -1}, {-1, 1}},
-		'K': {{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {1, 1}, {1, -1}, {-1, 1}},
-		'N': {
-			{-2, -1, deltaOtherEmpty, -1, 0}, {-2, 1, deltaOtherEmpty, -1, 0},
-			{-1, -2, deltaOtherEmpty, 0, -1}, {1, -2, deltaOtherEmpty, 0, -1},
-			{2, -1, deltaOtherEmpty, 1, 0}, {2, 1, deltaOtherEmpty, 1, 0},
-			{-1, 2, deltaOtherEmpty, 0, 1}, {1, 2, deltaOtherEmpty, 0, 1},
-		},
-		'p': {{1, 0, deltaEmpty}, {1, -1, deltaEnemy}, {1, 1, deltaEnemy}},
-		'r': {{-1, 0}, {1, 0}, {0, -1}, {0, 1}},
-		'b': {{-1, -1}, {1, 1}, {1, -1}, {-1, 1}},
-		'k': {{-1, 0}, {1, 0}, {0, -1}, {0, 1}, {-1, -1}, {1, 1}, {1, -1}, {-1, 1}},
-		'n': {
-			{-2, -1, deltaOtherEmpty, -1, 0}, {-2, 1, deltaOtherEmpty, -1, 0},
-			{-1, -2, deltaOtherEmpty, 0, -1}, {1, -2, deltaOtherEmpty, 0, -1},
-			{2, -1, deltaOtherEmpty, 1, 0}, {2, 1, deltaOtherEmpty, 1, 0},
-			{-1, 2, deltaOtherEmpty, 0, 1}, {1, 2, deltaOtherEmpty, 0, 1},
-		},
-	}
-	promos = map[byte][]byte{
-		'P': {'R', 'B', 'N'},
-		'p': {'r', 'b', 'n'},
-	}
-	undoPromos = map[byte]byte{
-		'R': 'P', 'B': 'P', 'N': 'P',
-		'r': 'p', 'b': 'p', 'n': 'p',
-	}
-	deadX = map[byte]int{
-		'R': 5, 'B': 5, 'N': 5, 'P': 5,
-		'r': 4, 'b': 4, 'n': 4, 'p': 4,
-	}
-	deadY = map[byte]int{
-		'R': 0, 'B': 1, 'N': 2, 'P': 3,
-		'r': 0, 'b': 1, 'n': 2, 'p': 3,
-	}
-	deadXY = [][]byte{
-		[]byte("RBNP"),
-		[]byte("rbnp"),
-	}
-)
-
 // New creates a new core.
-func New(writer io.Writer, config config.Config) *Core {
+func New(writer io.Writer, config config.Config) (*Core, error) {
 	res := &Core{
 		writer: writer, config: config,
-		board: [6][4]byte{
-			[4]byte([]byte("bnrk")),
-			[4]byte([]byte("   p")),
-			[4]byte([]byte("P   ")),
-			[4]byte([]byte("KRNB")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
-		memo: []map[[6][4]byte]Memo{
-			make(map[[6][4]byte]Memo, 100000),
-			make(map[[6][4]byte]Memo, 100000),
-		},
+		path:          [2]map[Position]bool{{}, {}},
 		sharedMoves:   make([]move.Move, 0, 15),
+		saved:         time.Now(),
 		clearTerminal: "\033[H\033[2J"}
-	if len(config.Board) > 1 {
-		for i, row := range strings.Split(config.Board, ",") {
-			res.board[i] = [4]byte([]byte(row))
+	pieceRules := rules.Default()
+	if config.RulesFile != "" {
+		var err error
+		if pieceRules, err = rules.Load(config.RulesFile); err != nil {
+			return nil, err
 		}
 	}
-	return res
+	if config.EnableBlockedKnight {
+		pieceRules = pieceRules.WithBlockedKnight()
+	}
+	res.rules = pieceRules
+	var err error
+	if res.pieces, err = newPieces(pieceRules, config); err != nil {
+		return nil, err
+	}
+	if err := res.parse(config.Board); err != nil {
+		return nil, err
+	}
+	res.root = strings.Join(res.rows(), ",")
+	if res.orderer, err = res.newMoveOrderer(config.MoveOrder); err != nil {
+		return nil, err
+	}
+	if res.table, err = newTable(config); err != nil {
+		return nil, err
+	}
+	if isBounded(config) {
+		res.decisive = map[uint64]bool{}
+	}
+	return res, nil
+}
+
+// Close releases the files of the memo table.
+func (c *Core) Close() error {
+	return c.table.Close()
 }
 
 // Solve solves the board.
 func (c *Core) Solve() {
-	res, maxDepth := c.solve()
-	fmt.Fprintf(c.writer, "\nmax depth: %d\n", maxDepth)
+	res := c.solve()
+	// A repetition is a draw only on the current path, so the draws memoized
+	// after a repetition can be wrong on other paths, while wins and losses
+	// never depend on one. The draws are searched again, keeping the wins and
+	// losses, until the board is decided or a pass finds no new ones. A bounded
+	// table can drop wins and losses that are then found again, so only the
+	// ones found for the first time count.
+	for c.repeated > 0 && res == 0 && c.found > 0 {
+		c.table.Retain(func(memo Memo) bool { return memo.Value != 0 })
+		res = c.solve()
+	}
+	fmt.Fprintf(c.writer, "\nmax depth: %d\n", c.maxDepth+1)
+	fmt.Fprintf(c.writer, "nodes: %d\n", c.nodes)
+	fmt.Fprintf(c.writer, "passes: %d\n", c.passes)
+	if isBounded(c.config) {
+		fmt.Fprintf(c.writer, "evicted: %d\n", c.evicted+c.table.Evicted())
+	}
 	fmt.Fprintf(c.writer, "overall res: %d\n", res)
+	fmt.Fprintf(c.writer, "termination: %s\n", c.termination())
 	if c.config.EnableShow {
 		c.show(nil)
 	}
 }
 
-// State contains the recursion state.
+// State contains the recursion state, with its NumMoves moves in the move
+// arena from First.
 type State struct {
-	Moves    [100]move.Move
+	First    int
 	NumMoves int
 	Move     move.Move
+	Best     move.Move
 	Value    int
 	Next     int
 	Index    int
 	What     byte
+	Promoted uint64
 }
 
-func (c *Core) solve() (int, int) {
-	stack := make([]State, 0, 100000)
-	c.call(&stack)
+// solve runs a pass, or continues the pass of a resumed checkpoint, and
+// returns the value of the board.
+func (c *Core) solve() int {
+	if len(c.stack) == 0 {
+		c.passes++
+		c.repeated, c.found = 0, 0
+		c.stack, c.arena = c.stack[:0], c.arena[:0]
+		c.call(&c.stack)
+	}
 	overall := -1
-	maxDepth := 0
 	maxVisited := 0
-	for len(stack) > 0 {
-		state, depth, turn := getState(stack)
-		c.updateMaxDepth(&maxDepth, depth)
+	for steps := 0; len(c.stack) > 0; steps++ {
+		if steps%checkpointSteps == 0 {
+			c.checkpoint()
+		}
+		state, depth, turn := getState(c.stack)
+		c.updateMaxDepth(&c.maxDepth, depth)
 		c.updateMaxVisited(&maxVisited)
 		if state.Index == 0 {
 			c.print("after move", state.Value, depth, turn, printconfig.PrintConfig{ClearTerminal: true})
 		}
+		if res, ok := c.checkMate(state.NumMoves, depth, turn); ok {
+			state.Value = res
+			overall = c.doReturn(&c.stack)
+			continue
+		}
 		if res, ok := c.staleMate(state.NumMoves, depth, turn); ok {
 			state.Value = res
-			overall = c.doReturn(&stack)
+			overall = c.doReturn(&c.stack)
 			continue
 		}
 		if state.Index < state.NumMoves {
-			state.Move = state.Moves[state.Index]
+			state.Move = c.arena[state.First+state.Index]
 			if res, ok := c.deadKing(state.Move, depth, turn); ok {
-				state.Value = res
-				overall = c.doReturn(&stack)
+				state.Value, state.Best = res, state.Move
+				overall = c.doReturn(&c.stack)
 				continue
 			}
+			state.Promoted = c.promoted
 			state.What = c.doMove(state.Move, state.Value, depth, turn)
 			state.Next = 0
-			if memo, ok := c.memo[turn][c.board]; ok && memo.Value != -2 {
+			if memo, ok := c.table.Get(turn, c.key()); ok {
 				state.Next = memo.Value
 				c.print("solved[]", state.Next, depth, turn, printconfig.PrintConfig{Move: state.Move})
-			} else if _, ok := c.memo[turn][c.board]; ok {
+			} else if c.path[turn][c.key()] {
+				c.repeated++
 				c.print("repeated", state.Next, depth, turn, printconfig.PrintConfig{Move: state.Move})
 			} else {
-				c.memo[turn][c.board] = Memo{Value: -2}
-				c.call(&stack)
+				c.call(&c.stack)
 				continue
 			}
-			c.afterReturn(stack)
+			c.afterReturn(c.stack)
 			continue
 		}
 		if state.Value == -1 {
-			memo := c.memo[(turn+1)%2][c.board]
-			memo.Move = state.Moves[0]
-			c.memo[(turn+1)%2][c.board] = memo
+			state.Best = c.arena[state.First]
 		}
-		c.print("final res", state.Value, depth, turn, printconfig.PrintConfig{Move: c.memo[(turn+1)%2][c.board].Move})
-		overall = c.doReturn(&stack)
+		c.print("final res", state.Value, depth, turn, printconfig.PrintConfig{Move: state.Best})
+		overall = c.doReturn(&c.stack)
 	}
-	return overall, maxDepth + 1
+	return overall
 }
 
 func getState(stack []State) (*State, int, int) {
@@ -203,7 +220,7 @@ func (c *Core) updateMaxDepth(maxDepth *int, depth int) {
 }
 
 func (c *Core) updateMaxVisited(maxVisited *int) {
-	numVisited := len(c.memo[0])
+	numVisited := c.table.Stored()
 	if numVisited > *maxVisited {
 		*maxVisited = numVisited
 		if c.config.PrintDepth && *maxVisited%10000000 == 0 {
@@ -214,40 +231,58 @@ func (c *Core) updateMaxVisited(maxVisited *int) {
 
 func (c *Core) call(stack *[]State) {
 	*stack = append(*stack, State{Value: -1})
-	state, _, turn := getState(*stack)
+	state, depth, turn := getState(*stack)
+	c.path[(turn+1)%2][c.key()] = true
 
+	c.nodes++
 	c.sharedMoves = c.sharedMoves[:0]
 	c.moves(&c.sharedMoves, turn)
-	for i, move := range c.sharedMoves {
-		state.Moves[i] = move
-	}
-	state.NumMoves = len(c.sharedMoves)
+	c.orderer.Order(c.sharedMoves, turn, depth)
+	state.First, state.NumMoves = len(c.arena), len(c.sharedMoves)
+	c.arena = append(c.arena, c.sharedMoves...)
 }
 
 func (c *Core) doReturn(stack *[]State) int {
-	state, depth, _ := getState(*stack)
+	state, depth, turn := getState(*stack)
 
 	next := -state.Value
+	delete(c.path[(turn+1)%2], c.key())
+	c.table.Put((turn+1)%2, c.key(), Memo{Value: next, Move: state.Best}, depth)
 
+	c.arena = c.arena[:state.First]
 	*stack = (*stack)[:depth]
 	if depth == 0 {
 		return state.Value
 	}
-	state, depth, turn := getState(*stack)
+	if next != 0 && c.firstFound((turn+1)%2, c.key()) {
+		c.found++
+	}
+	state, depth, turn = getState(*stack)
 
-	memo := c.memo[turn][c.board]
-	memo.Value = next
-	c.memo[turn][c.board] = memo
 	state.Next = next
 	c.print("solve()", next, depth, turn, printconfig.PrintConfig{Move: state.Move})
 	c.afterReturn(*stack)
 	return state.Value
 }
 
+// firstFound returns whether the win or loss of the position is found for
+// the first time, which is always the case for tables that keep every memo.
+func (c *Core) firstFound(turn int, position Position) bool {
+	if c.decisive == nil {
+		return true
+	}
+	key := hash(turn, position)
+	if c.decisive[key] {
+		return false
+	}
+	c.decisive[key] = true
+	return true
+}
+
 func (c *Core) afterReturn(stack []State) {
 	state, depth, turn := getState(stack)
-	c.undoMove(state.Move, state.What)
-	if c.updateValue(&state.Value, state.Next, state.Move, depth, turn) {
+	c.undoMove(state.Move, state.What, state.Promoted)
+	if c.updateValue(state, depth, turn) {
 		state.Index = state.NumMoves
 	}
 	state.Index++
@@ -268,9 +303,14 @@ func (c *Core) print(message string, value, depth, turn int, cfg printconfig.Pri
 			"move: %s (%d, %d) => %s (%d, %d)\n",
 			c.what(fx, fy), fx, fy, c.what(tx, ty), tx, ty)
 	}
-	fmt.Fprintln(c.writer, "______")
-	fmt.Fprintln(c.writer, "|"+string(bytes.Join(toBytes(c.board), []byte("|\n|")))+"|")
-	fmt.Fprintln(c.writer, "‾‾‾‾‾‾")
+	rows := c.rows()
+	width := 0
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+	fmt.Fprintln(c.writer, strings.Repeat("_", width+2))
+	fmt.Fprintln(c.writer, "|"+strings.Join(rows, "|\n|")+"|")
+	fmt.Fprintln(c.writer, strings.Repeat("‾", width+2))
 	if cfg.ClearTerminal {
 		time.Sleep(c.config.SleepDuration)
 		fmt.Fprint(c.writer, c.clearTerminal)
@@ -281,44 +321,53 @@ func (c *Core) what(x, y int) string {
 	return string(c.board[x][y])
 }
 
-func toBytes(board [6][4]byte) [][]byte {
-	res := [][]byte{}
-	for _, row := range board {
-		one := []byte{}
-		for _, v := range row {
-			one = append(one, v)
-		}
-		res = append(res, one)
+func (c *Core) notation(move move.Move) string {
+	fx, fy, tx, ty := move.Get()
+	if move.IsDrop() {
+		return fmt.Sprintf("%c@%d%d", c.deadXY[fx][fy], tx, ty)
+	}
+	separator := "-"
+	if move.IsCapture() {
+		separator = "x"
+	}
+	res := fmt.Sprintf("%c%d%d%s%d%d", c.board[fx][fy], fx, fy, separator, tx, ty)
+	if promotion := move.Promotion(); promotion != 0 {
+		res += "=" + string(c.promos[c.board[fx][fy]][promotion-1])
 	}
 	return res
 }
 
 func (c *Core) moves(moves *[]move.Move, turn int) {
-	for i := 0; i < 4; i++ {
-		for j := 0; j < 4; j++ {
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
 			piece := c.board[i][j]
-			if colors[piece] != turn {
+			if c.colors[piece] != turn {
 				continue
 			}
 			c.deltas(moves, turn, i, j)
 		}
 	}
-	if !c.config.EnableDrop {
-		c.sort(*moves)
-		return
+	if c.config.EnableDrop {
+		c.drops(moves, turn)
 	}
-	for index := 0; index < 4; index++ {
-		value := c.board[4+turn][index]
-		if value == '0' {
+	if c.config.EnableCheckmate {
+		c.legal(moves, turn)
+	}
+	c.sort(*moves)
+}
+
+func (c *Core) drops(moves *[]move.Move, turn int) {
+	for index := 0; index < len(c.deadXY[turn]); index++ {
+		if c.hands[turn][index] == 0 {
 			continue
 		}
-		piece := deadXY[turn][index]
-		for i := 0; i < 4; i++ {
-			for j := 0; j < 4; j++ {
+		piece := c.deadXY[turn][index]
+		for i := 0; i < c.height; i++ {
+			for j := 0; j < c.width; j++ {
 				if c.board[i][j] != ' ' {
 					continue
 				}
-				if c.config.EnablePromotion && ((i == 0 && piece == 'P') || (i == 3 && piece == 'p')) {
+				if c.config.EnablePromotion && len(c.promos[piece]) > 0 && i == c.promotionRank(piece) {
 					continue
 				}
 				move := move.NewMove(turn, index, i, j, false, false)
@@ -327,79 +376,68 @@ func (c *Core) moves(moves *[]move.Move, turn int) {
 			}
 		}
 	}
-	c.sort(*moves)
 }
 
 func (c *Core) deltas(moves *[]move.Move, turn, i, j int) {
 	piece := c.board[i][j]
-	for _, delta := range deltas[piece] {
-		kind := 0
-		if len(delta) > 2 {
-			kind = delta[2]
-		}
-		ni := i + delta[0]
-		nj := j + delta[1]
-		if ni < 0 || ni >= 4 || nj < 0 || nj >= 4 {
-			continue
-		}
-		nextTurn := (turn + 1) % 2
-		if (kind == deltaDefault || kind == deltaOtherEmpty) &&
-			c.board[ni][nj] != ' ' && colors[c.board[ni][nj]] != nextTurn {
-			continue
-		}
-		if kind == deltaEmpty && c.board[ni][nj] != ' ' {
-			continue
-		}
-		if kind == deltaEnemy && colors[c.board[ni][nj]] != nextTurn {
-			continue
-		}
-		if kind == deltaOtherEmpty && c.board[i+delta[3]][j+delta[4]] != ' ' {
-			continue
+	nextTurn := (turn + 1) % 2
+	for _, delta := range c.vectors[piece] {
+		for ni, nj := i+delta.x, j+delta.y; c.inside(ni, nj); ni, nj = ni+delta.x, nj+delta.y {
+			target := c.board[ni][nj]
+			if target == hole && delta.slide {
+				continue
+			}
+			if target != ' ' && c.colors[target] != nextTurn {
+				break
+			}
+			if delta.leg && !c.passable(i+delta.legX, j+delta.legY) {
+				break
+			}
+			if delta.moveOnly && target != ' ' {
+				break
+			}
+			if !delta.captureOnly || target != ' ' {
+				c.appendMove(moves, piece, i, j, ni, nj)
+			}
+			if !delta.slide || target != ' ' {
+				break
+			}
 		}
-		move := move.NewMove(
-			i, j, ni, nj,
-			c.board[ni][nj] == 'k' || c.board[ni][nj] == 'K',
-			c.board[ni][nj] != ' ')
-		if c.config.EnablePromotion && ((ni == 0 && piece == 'P') || (ni == 3 && piece == 'p')) {
-			move.SetPromotion(1)
-			*moves = append(*moves, move)
-			move.SetPromotion(2)
+	}
+}
+
+func (c *Core) inside(i, j int) bool {
+	return i >= 0 && i < c.height && j >= 0 && j < c.width
+}
+
+func (c *Core) passable(i, j int) bool {
+	return c.inside(i, j) && (c.board[i][j] == ' ' || c.board[i][j] == hole)
+}
+
+func (c *Core) appendMove(moves *[]move.Move, piece byte, i, j, ni, nj int) {
+	move := move.NewMove(i, j, ni, nj, c.kings[c.board[ni][nj]], c.board[ni][nj] != ' ')
+	if c.config.EnablePromotion && len(c.promos[piece]) > 0 && ni == c.promotionRank(piece) {
+		for promotion := 1; promotion < len(c.promos[piece]); promotion++ {
+			move.SetPromotion(promotion)
 			*moves = append(*moves, move)
-			move.SetPromotion(3)
 		}
-		*moves = append(*moves, move)
+		move.SetPromotion(len(c.promos[piece]))
 	}
+	*moves = append(*moves, move)
 }
 
-func (c *Core) sort(moves []move.Move) {
-	slices.SortFunc(moves, func(i, j move.Move) int {
-		if i.IsKing() {
-			return -1
-		}
-		if j.IsKing() {
-			return 1
-		}
-		if i.IsCapture() {
-			return -1
-		}
-		if j.IsCapture() {
-			return 1
-		}
-		if i.IsDrop() {
-			return -1
-		}
-		if j.IsDrop() {
-			return 1
-		}
+func (c *Core) promotionRank(piece byte) int {
+	if c.colors[piece] == 0 {
 		return 0
-	})
+	}
+	return c.height - 1
 }
 
 func (c *Core) staleMate(moves, depth, turn int) (int, bool) {
 	if moves != 0 {
 		return 0, false
 	}
-	res := 0
+	res := c.config.StaleMate.Value()
 	c.print("stalemate", res, depth, turn, printconfig.PrintConfig{})
 	return res, true
 }
@@ -409,87 +447,106 @@ func (c *Core) deadKing(move move.Move, depth, turn int) (int, bool) {
 		return 0, false
 	}
 	res := 1
-	memo := c.memo[(turn+1)%2][c.board]
-	memo.Move = move
-	c.memo[(turn+1)%2][c.board] = memo
 	c.print("dead king", res, depth, turn, printconfig.PrintConfig{Move: move})
 	return res, true
 }
 
 func (c *Core) doMove(move move.Move, res, depth, turn int) byte {
 	c.print("before move", res, depth, turn, printconfig.PrintConfig{Move: move})
+	return c.applyMove(move)
+}
+
+func (c *Core) applyMove(move move.Move) byte {
 	what := c.board[move.ToX()][move.ToY()]
 	from := c.board[move.FromX()][move.FromY()]
+	captured := c.handPiece(what, c.promoted&promotedBit(move.ToX(), move.ToY()) != 0)
 	if move.IsDrop() {
-		from = deadXY[move.FromX()][move.FromY()]
-		c.board[4+move.FromX()][move.FromY()]--
+		from = c.deadXY[move.FromX()][move.FromY()]
+		c.addHand(move.FromX(), move.FromY(), -1)
 	} else {
 		c.board[move.FromX()][move.FromY()] = ' '
 	}
 	c.board[move.ToX()][move.ToY()] = from
-	if move.IsCapture() && !move.IsKing() && what != 'x' && what != 'X' {
-		c.board[deadX[what]][deadY[what]]++
+	if _, ok := c.deadX[captured]; ok && move.IsCapture() && !move.IsKing() {
+		c.addHand(c.deadX[captured], c.deadY[captured], 1)
 	}
+	c.movePromoted(move)
 	promotion := move.Promotion()
 	if promotion == 0 {
 		return what
 	}
-	c.board[move.ToX()][move.ToY()] = promos[c.board[move.ToX()][move.ToY()]][promotion-1]
+	c.board[move.ToX()][move.ToY()] = c.promos[c.board[move.ToX()][move.ToY()]][promotion-1]
 	return what
 }
 
-func (c *Core) undoMove(move move.Move, what byte) {
+func (c *Core) undoMove(move move.Move, what byte, promoted uint64) {
+	captured := c.handPiece(what, promoted&promotedBit(move.ToX(), move.ToY()) != 0)
 	if move.IsDrop() {
-		c.board[4+move.FromX()][move.FromY()]++
+		c.addHand(move.FromX(), move.FromY(), 1)
 	} else {
 		c.board[move.FromX()][move.FromY()] = c.board[move.ToX()][move.ToY()]
 	}
 	c.board[move.ToX()][move.ToY()] = what
-	if move.IsCapture() && !move.IsKing() && what != 'x' && what != 'X' {
-		c.board[deadX[what]][deadY[what]]--
+	if _, ok := c.deadX[captured]; ok && move.IsCapture() && !move.IsKing() {
+		c.addHand(c.deadX[captured], c.deadY[captured], -1)
 	}
+	c.promoted = promoted
 	promotion := move.Promotion()
 	if promotion == 0 {
 		return
 	}
-	c.board[move.FromX()][move.FromY()] = undoPromos[c.board[move.FromX()][move.FromY()]]
+	c.board[move.FromX()][move.FromY()] = c.undoPromos[c.board[move.FromX()][move.FromY()]]
 }
 
-func (c *Core) updateValue(res *int, next int, move move.Move, depth, turn int) bool {
-	if next <= *res {
+func (c *Core) updateValue(state *State, depth, turn int) bool {
+	if state.Next <= state.Value {
 		return false
 	}
-	*res = next
-	memo := c.memo[(turn+1)%2][c.board]
-	memo.Move = move
-	c.memo[(turn+1)%2][c.board] = memo
-	c.print("updated res", *res, depth, turn, printconfig.PrintConfig{Move: move})
-	return *res == 1
+	state.Value, state.Best = state.Next, state.Move
+	c.print("updated res", state.Value, depth, turn, printconfig.PrintConfig{Move: state.Move})
+	if state.Value == 1 {
+		c.orderer.Cutoff(state.Move, turn, depth)
+	}
+	return state.Value == 1
 }
 
-func (c *Core) show(fn func()) {
+// memo returns the memo of the board for the player that just moved.
+func (c *Core) memo(turn int) Memo {
+	memo, _ := c.table.Get(turn, c.key())
+	return memo
+}
+
+func (c *Core) show(fn func() move.Move) {
 	c.config.MaxPrintDepth = 0
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
 	res := 123
-	visited := []map[[6][4]byte]interface{}{{}, {}}
+	visited := []map[Position]interface{}{{}, {}}
 	depth := 0
 	turn := 0
 	c.print("show", res, depth, turn, printconfig.PrintConfig{})
+	c.frames = []animation.Frame{c.frame("start")}
 	for {
-		if _, ok := visited[turn][c.board]; ok {
+		if _, ok := visited[turn][c.key()]; ok {
 			break
 		}
-		visited[turn][c.board] = true
-		move := c.memo[(turn+1)%2][c.board].Move
+		visited[turn][c.key()] = true
+		move := c.memo((turn + 1) % 2).Move
 		if move == 0 {
 			break
 		}
+		notation := c.notation(move)
 		c.doMove(move, res, depth, turn)
 		depth++
-		res = c.memo[turn][c.board].Value
+		res = c.memo(turn).Value
+		c.frames = append(c.frames, c.frame(fmt.Sprintf("%d. %s", depth, notation), fmt.Sprintf("res: %d", res)))
 		turn = (turn + 1) % 2
 		c.print("after move", res, depth, turn, printconfig.PrintConfig{Move: move})
 		if fn != nil {
-			fn()
+			move = fn()
+			notation = c.notation(move)
+			c.doMove(move, res, depth, turn)
+			c.frames = append(c.frames, c.frame(notation, fmt.Sprintf("res: %d", c.memo(turn).Value)))
 			turn = (turn + 1) % 2
 			c.print("after move", res, depth, turn, printconfig.PrintConfig{})
 		}
@@ -498,16 +555,16 @@ func (c *Core) show(fn func()) {
 
 // Play plays a game agains the solution.
 func (c *Core) Play() {
-	c.show(func() {
+	c.show(func() move.Move {
 		fmt.Printf("> ")
 		var fx, fy, tx, ty int
 		fmt.Scanf("%d%d%d%d", &fx, &fy, &tx, &ty)
-		c.doMove(move.NewMove(fx, fy, tx, ty, false, c.board[tx][ty] != ' '), 123, 123, 123)
+		return move.NewMove(fx, fy, tx, ty, false, c.board[tx][ty] != ' ')
 	})
 }
 
-// RunAll runs all configs.
-func RunAll(writer io.Writer, configs []config.Config) {
+// RunAll runs all configs with the memo table options of table.
+func RunAll(writer io.Writer, table config.Config, configs []config.Config) {
 	buffers := []*bytes.Buffer{}
 	var wg sync.WaitGroup
 	for _, config := range configs {
@@ -515,7 +572,17 @@ func RunAll(writer io.Writer, configs []config.Config) {
 		buffers = append(buffers, &buffer)
 		wg.Go(func() {
 			config.MaxPrintDepth = -1
-			core := New(&buffer, config)
+			config.TableSize, config.TableDir = table.TableSize, table.TableDir
+			core, err := New(&buffer, config)
+			if err != nil {
+				fmt.Fprintln(&buffer, err)
+				return
+			}
+			defer func() {
+				if err := core.Close(); err != nil {
+					fmt.Fprintln(&buffer, err)
+				}
+			}()
 			core.Solve()
 		})
 	}
@@ -530,6 +597,24 @@ func RunAll(writer io.Writer, configs []config.Config) {
 		if config.EnableDrop {
 			desc = append(desc, "--enable_drop")
 		}
+		if config.EnableQueenPromotion {
+			desc = append(desc, "--enable_queen_promotion")
+		}
+		if config.EnableDemotion {
+			desc = append(desc, "--enable_demotion")
+		}
+		if config.EnableCheckmate {
+			desc = append(desc, "--enable_checkmate")
+		}
+		if config.EnableBlockedKnight {
+			desc = append(desc, "--enable_blocked_knight")
+		}
+		if config.MoveOrder != "" {
+			desc = append(desc, "--move_order="+config.MoveOrder)
+		}
+		if config.StaleMate != 0 {
+			desc = append(desc, "--stalemate="+config.StaleMate.String())
+		}
 		fmt.Fprintf(writer, "\n%s\n%s", strings.Join(desc, " "), buffers[i].String())
 	}
 }
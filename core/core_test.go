@@ -2,260 +2,1071 @@ package core
 
 import (
 	"bytes"
+	"flag"
+	"fmt"
+	"image/gif"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/kssilveira/chess-solver/config"
+	"github.com/kssilveira/chess-solver/move"
 )
 
+var update = flag.Bool("update", false, "update golden files in testdata")
+
+// golden compares got with the golden file, or updates it with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Errorf("WriteFile %s got err %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Errorf("ReadFile %s got err %v, run with -update to create it", path, err)
+		return
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from golden file, run with -update to accept:\n%s", path, diff(string(want), string(got)))
+	}
+}
+
+// diff returns the lines around the first difference between want and got.
+func diff(want, got string) string {
+	wantLines, gotLines := strings.Split(want, "\n"), strings.Split(got, "\n")
+	first := 0
+	for first < len(wantLines) && first < len(gotLines) && wantLines[first] == gotLines[first] {
+		first++
+	}
+	res := []string{fmt.Sprintf("first difference at line %d of %d (want) and %d (got):", first+1, len(wantLines), len(gotLines))}
+	for i := max(0, first-3); i < first; i++ {
+		res = append(res, "  "+wantLines[i])
+	}
+	for i := first; i < min(first+5, len(wantLines)); i++ {
+		res = append(res, "- "+wantLines[i])
+	}
+	for i := first; i < min(first+5, len(gotLines)); i++ {
+		res = append(res, "+ "+gotLines[i])
+	}
+	return strings.Join(res, "\n")
+}
+
+type solveInput struct {
+	name                 string
+	want                 int
+	board                string
+	maxPrintDepth        int
+	disablePromotion     bool
+	disableDrop          bool
+	enableQueenPromotion bool
+	rulesFile            string
+	enableDemotion       bool
+	enableCheckmate      bool
+	staleMate            config.Outcome
+	enableBlockedKnight  bool
+}
+
+func (in solveInput) config() config.Config {
+	return config.Config{
+		Board: in.board, EnablePromotion: !in.disableDrop, EnableDrop: !in.disableDrop,
+		EnableQueenPromotion: in.enableQueenPromotion, RulesFile: in.rulesFile,
+		EnableDemotion: in.enableDemotion, EnableCheckmate: in.enableCheckmate,
+		StaleMate: in.staleMate, EnableBlockedKnight: in.enableBlockedKnight}
+}
+
+var solveInputs = []solveInput{{
+	name: "empty", want: 0, board: "    ,    ,    ,    ,0000,0000",
+}, {
+	name: "emptyWin", want: 1, staleMate: config.Win, board: "    ,    ,    ,    ,00000,00000",
+}, {
+	name: "emptyLoss", want: -1, staleMate: config.Loss, board: "    ,    ,    ,    ,00000,00000",
+}, {
+	name: "P1", want: 0, board: "   p,    ,    ,P   ,0000,0000",
+}, {
+	name: "P2", want: 0, board: "  p ,    ,    , P  ,0000,0000",
+}, {
+	name: "P3", want: 0, board: " p  ,    ,    ,  P ,0000,0000",
+}, {
+	name: "P4", want: 0, board: "p   ,    ,    ,   P,0000,0000",
+}, {
+	name: "PX", want: 0, board: "xxx , P  ,    ,    ,0000,0000",
+}, {
+	name: "PXWin", want: -1, staleMate: config.Win, board: "xxx , P  ,    ,    ,00000,00000",
+}, {
+	name: "PXLoss", want: 1, staleMate: config.Loss, board: "xxx , P  ,    ,    ,00000,00000",
+}, {
+	name: "R", want: 0, board: "   r,    ,    ,R   ,0000,0000",
+}, {
+	name: "B", want: 0, board: "   b,    ,    ,B   ,0000,0000",
+}, {
+	name: "K", want: 0, board: "   k,    ,    ,K   ,0000,0000",
+}, {
+	name: "Kk", want: 1, board: "    ,  k , K  ,    ,0000,0000",
+}, {
+	name: "Kk2", want: -1, board: "    , k  ,    ,K k ,0000,0000",
+}, {
+	name: "NB", want: 0, board: "nx  ,X   ,   x,  XN,0000,0000",
+}, {
+	name: "NBBlocked", want: 0, enableBlockedKnight: true, board: "nx  ,X   ,   x,  XN,0000,0000",
+}, {
+	name: "N", want: 0, board: "nx  ,    ,    ,  XN,0000,0000",
+}, {
+	name: "NBlocked", want: 0, enableBlockedKnight: true, board: "nx  ,    ,    ,  XN,0000,0000",
+}, {
+	name: "RNk", want: 1, disablePromotion: true, disableDrop: true, maxPrintDepth: -1, board: "  R ,k   , R  ,R  N,0000,0000",
+}, {
+	name: "RNkBlocked", want: 1, enableBlockedKnight: true, disablePromotion: true, disableDrop: true, maxPrintDepth: -1,
+	board: "  R ,k   , R  ,R  N,0000,0000",
+}, {
+	name: "PkR", want: 1, board: "k   ,xxP ,    ,    ,0000,0000",
+}, {
+	name: "PrD", want: 0, enableDemotion: true, board: " r  ,P   ,    ,   k,00000,00000",
+}, {
+	name: "PkN", want: 1, board: "    , xP ,kx  ,xx  ,0000,0000",
+}, {
+	name: "NWall", want: 0, board: "n#  ,#   ,   #,  #N,0000,0000",
+}, {
+	name: "RHole", want: 1, rulesFile: "testdata/chess.json", board: "r  .,  . ,    ,. kR,0000,0000",
+}, {
+	name: "PkNBlocked", want: 1, enableBlockedKnight: true, board: "    , xP ,kx  ,xx  ,0000,0000",
+}, {
+	name: "PkB", want: 1, board: "    ,x P ,kx  ,xx  ,0000,0000",
+}, {
+	name: "D1", want: 0, board: "    ,    ,    ,    ,1000,1000",
+}, {
+	name: "D2", want: 0, board: "    ,    ,    ,    ,0100,0100",
+}, {
+	name: "D3", want: 0, board: "    ,    ,    ,    ,0010,0010",
+}, {
+	name: "D4", want: 0, board: "    ,    ,    ,    ,0001,0001",
+}, {
+	name: "D5", want: 0, board: "    ,    ,    ,    ,00001,00001",
+}, {
+	name: "Q", want: 0, board: "   q,    ,    ,Q   ,0000,0000",
+}, {
+	name: "PQ", want: 1, enableQueenPromotion: true, board: "    ,P  k,    ,K   ,00000,00000",
+}, {
+	name: "Rchess", want: 0, rulesFile: "testdata/chess.json", board: "   r,    ,    ,R   ,00000,00000",
+}, {
+	name: "QKk", want: 1, board: "k   ,    ,K Q ,    ,00000,00000",
+}, {
+	name: "QKkCheckmate", want: 1, enableCheckmate: true, board: "k   ,    ,K Q ,    ,00000,00000",
+}, {
+	name: "KkCheckmate", want: 1, enableCheckmate: true, board: "    ,  k , K  ,    ,00000,00000",
+}, {
+	name: "RNkCheckmate", want: 1, enableCheckmate: true, disablePromotion: true, disableDrop: true, maxPrintDepth: -1, board: "  R ,k   , R  ,R  N,00000,00000",
+}, {
+	name: "K3x3", want: 0, board: "  k,   ,K  ,0000,0000",
+}, {
+	name: "P3x3", want: 0, board: "  p,   ,P  ,0000,0000",
+}, {
+	name: "P4x5", want: 0, board: "   p,    ,    ,    ,P   ,0000,0000",
+}, {
+	name: "R5x5", want: 0, board: "    r,     ,     ,     ,R    ,0000,0000",
+}, {
+	name: "P5x6", want: 0, board: "    p,     ,     ,     ,     ,P    ,0000,0000",
+}}
+
 func TestSolve(t *testing.T) {
+	for _, in := range solveInputs {
+		config := in.config()
+		config.MaxPrintDepth, config.EnableShow = 5, true
+		if in.maxPrintDepth != 0 {
+			config.MaxPrintDepth = in.maxPrintDepth
+		}
+		var out bytes.Buffer
+		core, err := New(&out, config)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		core.clearTerminal = "\n------\n"
+		core.Solve()
+		if got, _ := core.value(0); got != in.want {
+			t.Errorf("Solve %s got overall res %d want %d", in.name, got, in.want)
+		}
+		golden(t, in.name+".txt", out.Bytes())
+	}
+}
+
+func TestNew(t *testing.T) {
 	inputs := []struct {
-		name             string
-		board            [6][4]byte
-		maxPrintDepth    int
-		disablePromotion bool
-		disableDrop      bool
+		board           string
+		enablePromotion bool
+		want            []string
+		err             bool
 	}{{
-		name: "empty", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "   k,    ,P   ,K   ",
+		want:  []string{"   k", "    ", "P   ", "K   ", "0000", "0000"},
 	}, {
-		name: "P1", board: [6][4]byte{
-			[4]byte([]byte("   p")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("P   ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "   q,    ,    ,K   ,0,0",
+		want:  []string{"   q", "    ", "    ", "K   ", "00000", "00000"},
 	}, {
-		name: "P2", board: [6][4]byte{
-			[4]byte([]byte("  p ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte(" P  ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "  k ,    ,    ,K   ",
+		want:  []string{"  k ", "    ", "    ", "K   "},
 	}, {
-		name: "P3", board: [6][4]byte{
-			[4]byte([]byte(" p  ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("  P ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,0001,1",
+		want:  []string{"    ", "    ", "    ", "    ", "0001", "1000"},
 	}, {
-		name: "P4", board: [6][4]byte{
-			[4]byte([]byte("p   ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("   P")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,12/0/0/1,0/0/0/0/2",
+		want:  []string{"    ", "    ", "    ", "    ", "12/0/0/1/0", "00002"},
 	}, {
-		name: "PX", board: [6][4]byte{
-			[4]byte([]byte("xxx ")),
-			[4]byte([]byte(" P  ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,9/9,0", err: true,
 	}, {
-		name: "R", board: [6][4]byte{
-			[4]byte([]byte("   r")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("R   ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,256/0,0", err: true,
 	}, {
-		name: "B", board: [6][4]byte{
-			[4]byte([]byte("   b")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("B   ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,000000,0", err: true,
 	}, {
-		name: "K", board: [6][4]byte{
-			[4]byte([]byte("   k")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("K   ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "   k,   ,P   ,K   ", err: true,
 	}, {
-		name: "Kk", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("  k ")),
-			[4]byte([]byte(" K  ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "   ?,    ,P   ,K   ", err: true,
 	}, {
-		name: "Kk2", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte(" k  ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("K k ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "       ,       ,       ,       ,       ,       ,       ", err: true,
 	}, {
-		name: "NB", board: [6][4]byte{
-			[4]byte([]byte("nx  ")),
-			[4]byte([]byte("X   ")),
-			[4]byte([]byte("   x")),
-			[4]byte([]byte("  XN")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,0/0/0/13,0",
+		want:  []string{"    ", "    ", "    ", "    ", "0/0/0/13", "0000"},
 	}, {
-		name: "N", board: [6][4]byte{
-			[4]byte([]byte("nx  ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("  XN")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,0/0/0/12,0", enablePromotion: true,
+		want: []string{"    ", "    ", "    ", "    ", "0/0/0/12", "0000"},
 	}, {
-		name: "RNk", disablePromotion: true, disableDrop: true, maxPrintDepth: -1, board: [6][4]byte{
-			[4]byte([]byte("  R ")),
-			[4]byte([]byte("k   ")),
-			[4]byte([]byte(" R  ")),
-			[4]byte([]byte("R  N")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "    ,    ,    ,    ,0/0/0/13,0", enablePromotion: true, err: true,
 	}, {
-		name: "PkR", board: [6][4]byte{
-			[4]byte([]byte("k   ")),
-			[4]byte([]byte("xxP ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "#   ,    ,    ,    ,0,0/0/0/12", enablePromotion: true, err: true,
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, config.Config{Board: in.board, EnablePromotion: in.enablePromotion})
+		if in.err {
+			if err == nil {
+				t.Errorf("New %q got rows %q want err", in.board, core.rows())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New %q got err %v", in.board, err)
+			continue
+		}
+		if got := core.rows(); !slices.Equal(got, in.want) {
+			t.Errorf("New %q got rows %q want %q", in.board, got, in.want)
+		}
+	}
+}
+
+func TestNeutral(t *testing.T) {
+	inputs := []struct {
+		board               string
+		rulesFile           string
+		enableBlockedKnight bool
+		want                []string
+	}{{
+		board: "R.r ,#   ,    ,    ", rulesFile: "testdata/chess.json",
+		want: []string{"R00x02"},
 	}, {
-		name: "PkN", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte(" xP ")),
-			[4]byte([]byte("kx  ")),
-			[4]byte([]byte("xx  ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "R.r ,#   ,    ,    ",
+		want:  []string{},
 	}, {
-		name: "PkB", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("x P ")),
-			[4]byte([]byte("kx  ")),
-			[4]byte([]byte("xx  ")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		board: "N   ,. # ,    ,    ", enableBlockedKnight: true,
+		want: []string{"N00-21"},
 	}, {
-		name: "D1", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("1000")),
-			[4]byte([]byte("1000")),
-		},
+		board: "N   ,# . ,    ,    ", enableBlockedKnight: true,
+		want: []string{},
 	}, {
-		name: "D2", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0100")),
-			[4]byte([]byte("0100")),
-		},
+		board: "P   ,.   ,    ,    ,00010,0",
+		want:  []string{"P@11", "P@12", "P@13", "P@20", "P@21", "P@22", "P@23", "P@30", "P@31", "P@32", "P@33"},
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, config.Config{
+			Board: in.board, RulesFile: in.rulesFile, EnableBlockedKnight: in.enableBlockedKnight,
+			EnablePromotion: true, EnableDrop: true})
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		moves := []move.Move{}
+		core.moves(&moves, 0)
+		got := []string{}
+		for _, move := range moves {
+			got = append(got, core.notation(move))
+		}
+		slices.Sort(got)
+		if !slices.Equal(got, in.want) {
+			t.Errorf("Neutral %v got %q want %q", in, got, in.want)
+		}
+	}
+}
+
+func TestDemotion(t *testing.T) {
+	inputs := []struct {
+		enableDemotion bool
+		want           string
+	}{{
+		want: "1000",
 	}, {
-		name: "D3", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0010")),
-			[4]byte([]byte("0010")),
-		},
+		enableDemotion: true, want: "0001",
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, config.Config{
+			Board: " r  ,P   ,    ,    ", EnablePromotion: true, EnableDemotion: in.enableDemotion})
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		board, promoted := core.board, core.promoted
+		promotion := move.NewMove(1, 0, 0, 0, false, false)
+		promotion.SetPromotion(1)
+		capture := move.NewMove(0, 1, 0, 0, false, true)
+		what1 := core.applyMove(promotion)
+		promoted1 := core.promoted
+		what2 := core.applyMove(capture)
+		if got := core.rows()[core.height+1]; got != in.want {
+			t.Errorf("Demotion %v got hand %q want %q", in, got, in.want)
+		}
+		core.undoMove(capture, what2, promoted1)
+		core.undoMove(promotion, what1, promoted)
+		if core.board != board || core.promoted != promoted {
+			t.Errorf("Demotion %v got %q %b want %q %b", in, core.rows(), core.promoted, board, promoted)
+		}
+	}
+}
+
+func TestPromoted(t *testing.T) {
+	inputs := []struct {
+		board          string
+		enableDemotion bool
+		want           uint64
+		err            bool
+	}{{
+		board: "R   ,   r,    ,   k,0000,0000,+00", enableDemotion: true, want: promotedBit(0, 0),
 	}, {
-		name: "D4", board: [6][4]byte{
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("0001")),
-			[4]byte([]byte("0001")),
-		},
+		board: "R   ,   r,    ,   k,+13+00", enableDemotion: true, want: promotedBit(0, 0) | promotedBit(1, 3),
+	}, {
+		board: "R   ,   r,    ,   k,0000,0000,+00", err: true,
+	}, {
+		board: "R   ,   r,    ,   k,0000,0000,+03", enableDemotion: true, err: true,
+	}, {
+		board: "R   ,   r,    ,   k,0000,0000,+40", enableDemotion: true, err: true,
+	}, {
+		board: "R   ,   r,    ,   k,0000,0000,+00+00", enableDemotion: true, err: true,
+	}, {
+		board: "R   ,   r,    ,   k,0000,0000,+0", enableDemotion: true, err: true,
 	}}
 	for _, in := range inputs {
-		config := config.Config{
-			MaxPrintDepth: 5, EnableShow: true, EnablePromotion: !in.disableDrop, EnableDrop: !in.disableDrop}
-		if in.maxPrintDepth != 0 {
-			config.MaxPrintDepth = in.maxPrintDepth
+		core, err := New(&bytes.Buffer{}, config.Config{Board: in.board, EnableDemotion: in.enableDemotion})
+		if in.err {
+			if err == nil {
+				t.Errorf("New %q got rows %q want err", in.board, core.rows())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("New %q got err %v", in.board, err)
+			continue
+		}
+		if core.promoted != in.want {
+			t.Errorf("New %q got promoted %b want %b", in.board, core.promoted, in.want)
+		}
+		rows := strings.Join(core.rows(), ",")
+		again, err := New(&bytes.Buffer{}, config.Config{Board: rows, EnableDemotion: true})
+		if err != nil || again.key() != core.key() {
+			t.Errorf("New %q from %q got err %v or a different position", rows, in.board, err)
+		}
+	}
+}
+
+func TestWriteGIF(t *testing.T) {
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{
+		Board: "KRNB,N   ,    ,   k", MaxPrintDepth: -1, EnableShow: true, GIFDelay: 250 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Solve()
+	var buffer bytes.Buffer
+	if err := core.WriteGIF(&buffer); err != nil {
+		t.Fatalf("WriteGIF got err %v", err)
+	}
+	golden(t, "KRNB.gif", buffer.Bytes())
+	res, err := gif.DecodeAll(&buffer)
+	if err != nil {
+		t.Fatalf("DecodeAll got err %v", err)
+	}
+	// The start frame and one frame for each move of the principal variation.
+	captions := [][]string{{"start"}, {"1. K00-11", "res: 1"}, {"2. k33-23", "res: -1"}, {"3. N02x23", "res: 0"}}
+	if got, want := len(res.Image), len(captions); got != want {
+		t.Errorf("WriteGIF got %d frames want %d", got, want)
+	}
+	for i, frame := range core.frames {
+		if i < len(captions) && !slices.Equal(frame.Caption, captions[i]) {
+			t.Errorf("WriteGIF frame %d got caption %q want %q", i, frame.Caption, captions[i])
+		}
+	}
+	for i, delay := range res.Delay {
+		if delay != 25 {
+			t.Errorf("WriteGIF frame %d got delay %d want 25", i, delay)
+		}
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{
+		Board: "  R ,k   , R  ,R  N", MaxPrintDepth: -1, DOTMaxDepth: 3, DOTBestOnly: true})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Solve()
+	var buffer bytes.Buffer
+	core.WriteDOT(&buffer)
+	if !bytes.HasPrefix(buffer.Bytes(), []byte("digraph {")) {
+		t.Errorf("WriteDOT got %q", buffer.String())
+	}
+	golden(t, "RNk.dot", buffer.Bytes())
+}
+
+func TestPerft(t *testing.T) {
+	inputs := []struct {
+		config config.Config
+		depth  int
+		want   int
+		divide string
+	}{{
+		config: config.Config{Board: "K   ,    ,    ,   k"}, depth: 0, want: 1,
+	}, {
+		config: config.Config{Board: "K   ,    ,    ,   k"}, depth: 1, want: 3,
+	}, {
+		config: config.Config{Board: "K   ,    ,    ,   k"}, depth: 2, want: 9,
+	}, {
+		// K01 and K10 have 5 moves and K11 has 8, including the king capture K11x22.
+		config: config.Config{Board: "K   ,    ,    ,   k"}, depth: 3, want: 54,
+	}, {
+		config: config.Config{Board: "    ,    ,    ,    ,1000,1000", EnableDrop: true}, depth: 1, want: 16,
+	}, {
+		config: config.Config{Board: "    ,    ,    ,    ,1000,1000", EnableDrop: true}, depth: 2, want: 240,
+	}, {
+		config: config.Config{Board: "    ,    ,    ,    ,0001,0000", EnableDrop: true}, depth: 1, want: 16,
+	}, {
+		config: config.Config{Board: "    ,    ,    ,    ,0001,0000", EnableDrop: true, EnablePromotion: true}, depth: 1, want: 12,
+	}, {
+		config: config.Config{Board: "    ,P   ,    ,   k", EnablePromotion: true}, depth: 2, want: 9,
+		divide: "P10-00=R: 3\nP10-00=B: 3\nP10-00=N: 3\n",
+	}, {
+		config: config.Config{Board: "    ,P   ,    ,   k", EnablePromotion: true, EnableQueenPromotion: true}, depth: 1, want: 4,
+	}, {
+		config: config.Config{Board: "    , r  , R  ,    ", EnableDrop: true}, depth: 2, want: 12,
+		divide: "R21x11: 0\nR21-31: 4\nR21-20: 4\nR21-22: 4\n",
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, in.config)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		key := core.key()
+		if got := core.Perft(in.depth); got != in.want {
+			t.Errorf("Perft %v got %d want %d", in, got, in.want)
+		}
+		if !strings.Contains(out.String(), in.divide) {
+			t.Errorf("Perft %v got %q want divide %q", in, out.String(), in.divide)
 		}
+		if core.key() != key {
+			t.Errorf("Perft %v changed board to %q", in, core.rows())
+		}
+	}
+}
+
+func TestEnumerate(t *testing.T) {
+	inputs := []struct {
+		name   string
+		config config.Config
+	}{{
+		name: "R", config: config.Config{Board: "   r,    ,    ,R   ,0000,0000", EnableDrop: true},
+	}, {
+		name: "PX", config: config.Config{Board: "xxx , P  ,    ,    ,0000,0000", EnableDrop: true},
+	}, {
+		name: "PXWin", config: config.Config{Board: "xxx , P  ,    ,    ,0000,0000", EnableDrop: true, StaleMate: config.Win},
+	}, {
+		name: "QKkCheckmate", config: config.Config{Board: "k   ,    ,K Q ,    ,00000,00000", EnableCheckmate: true},
+	}, {
+		name: "RNk", config: config.Config{Board: "  R ,k   , R  ,R  N,0000,0000"},
+	}, {
+		name: "PKR", config: config.Config{Board: "   k,    ,P   ,KR  ,0000,0000"},
+	}}
+	for _, in := range inputs {
+		in.config.MaxPrintDepth = -1
 		var out bytes.Buffer
-		core := New(&out, config)
-		core.clearTerminal = "\n------\n"
-		core.board = in.board
+		core, err := New(&out, in.config)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
 		core.Solve()
-		if err := os.WriteFile(filepath.Join("testdata", in.name+".txt"), out.Bytes(), 0644); err != nil {
-			t.Errorf("Solve %v got err %v", in, err)
+		want, _ := core.value(0)
+		var report bytes.Buffer
+		core.writer = &report
+		core.Enumerate()
+		if got := core.space.values[0]; got != want {
+			t.Errorf("Enumerate %v got res %d want %d", in, got, want)
+		}
+		if core.key() != core.space.nodes[0].position {
+			t.Errorf("Enumerate %v changed board to %q", in, core.rows())
+		}
+		var buffer bytes.Buffer
+		if err := core.WriteSpace(&buffer); err != nil {
+			t.Fatalf("WriteSpace %v got err %v", in, err)
 		}
+		if got, want := bytes.Count(buffer.Bytes(), []byte("\n")), len(core.space.nodes)+1; got != want {
+			t.Errorf("WriteSpace %v got %d lines want %d", in, got, want)
+		}
+		golden(t, in.name+".enumerate.txt", report.Bytes())
 	}
 }
 
-func BenchmarkSolve(b *testing.B) {
+func TestAnalyze(t *testing.T) {
 	inputs := []struct {
 		name  string
-		board [6][4]byte
+		board string
+		want  string
 	}{{
-		name: "Nk", board: [6][4]byte{
-			[4]byte([]byte("k R ")),
-			[4]byte([]byte("    ")),
-			[4]byte([]byte("RR  ")),
-			[4]byte([]byte("   N")),
-			[4]byte([]byte("0000")),
-			[4]byte([]byte("0000")),
-		},
+		name: "PKR", board: "   k,    ,P   ,KR  ", want: "W K30-21 res: 1 dtm: 11",
+	}, {
+		name: "PX", board: "xxx , P  ,    ,    ,0000,0000", want: "D P11x00 res: 0 dtm: -",
+	}, {
+		name: "Kk", board: "    ,  k , K  ,    ", want: "W K21x12 res: 1 dtm: 1",
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, config.Config{Board: in.board})
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		core.Analyze()
+		lines := strings.Split(out.String(), "\n")
+		if len(lines) < 3 || lines[2] != in.want {
+			t.Errorf("Analyze %v got %q want %q", in, lines, in.want)
+		}
+		golden(t, in.name+".analyze.txt", out.Bytes())
+	}
+}
+
+func TestExplain(t *testing.T) {
+	inputs := []struct {
+		name   string
+		config config.Config
+		move   string
+		want   []string
+	}{{
+		name: "KRk", config: config.Config{Board: " k  ,    , K  ,   R"}, move: "K21-11",
+		want: []string{"L K21-11 res: -1 dtm: 2", "  W k01x11 res: 1 dtm: 1"},
+	}, {
+		name: "RKk", config: config.Config{Board: "  k ,    , R  , K  ", ExplainMaxDepth: 3}, move: "R21-11",
+		want: []string{"D R21-11 res: 0 dtm: -", "  D k02x11 res: 0 dtm: -", "    D K31-30 res: 0 dtm: - ..."},
+	}, {
+		name: "QKkCheckmate", config: config.Config{Board: "k   ,    ,K Q ,    ", EnableCheckmate: true}, move: "K20-21",
+		want: []string{"W K20-21 res: 1 dtm: 3", "  L k00-01 res: -1 dtm: 2", "    W Q22-11 res: 1 dtm: 1"},
+	}}
+	for _, in := range inputs {
+		var out bytes.Buffer
+		core, err := New(&out, in.config)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		if err := core.Explain(in.move); err != nil {
+			t.Fatalf("Explain %v got err %v", in, err)
+		}
+		lines := strings.Split(out.String(), "\n")
+		if len(lines) < len(in.want)+2 || !slices.Equal(lines[2:len(in.want)+2], in.want) {
+			t.Errorf("Explain %v got %q want %q", in, lines, in.want)
+		}
+		golden(t, in.name+".explain.txt", out.Bytes())
+		var buffer bytes.Buffer
+		if err := core.WriteExplainDOT(&buffer, in.move); err != nil {
+			t.Fatalf("WriteExplainDOT %v got err %v", in, err)
+		}
+		if got, want := bytes.Count(buffer.Bytes(), []byte(" -> ")), len(lines)-3; got != want {
+			t.Errorf("WriteExplainDOT %v got %d edges want %d", in, got, want)
+		}
+		golden(t, in.name+".explain.dot", buffer.Bytes())
+		if err := core.Explain("K00-00"); err == nil {
+			t.Errorf("Explain %v got no err for illegal move", in)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{Board: "   k,    ,P   ,KR  "})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Enumerate()
+	var buffer bytes.Buffer
+	if err := core.WriteSpace(&buffer); err != nil {
+		t.Fatalf("WriteSpace got err %v", err)
+	}
+	inputs := []struct {
+		query string
+		want  string
+		err   bool
+	}{{
+		query: "   k,    ,P   ,KR  ", want: "turn: 0\nres: 1\ndtm: 11\nmove: K30-21\n",
+	}, {
+		query: "1\t   k,P   ,    ,KR  ,00000,00000", want: "turn: 1\nres: -1\ndtm: 16\nmove: k03-12\n",
+	}, {
+		query: "k   ,    ,    ,    ", want: "turn: 0\nres: ?\n",
+	}, {
+		query: "2\t   k,    ,P   ,KR  ", err: true,
+	}, {
+		query: "   z,    ,P   ,KR  ", err: true,
+	}}
+	var probeOut bytes.Buffer
+	probe, err := New(&probeOut, config.Config{})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	if err := probe.LoadTablebase(bytes.NewReader(buffer.Bytes())); err != nil {
+		t.Fatalf("LoadTablebase got err %v", err)
+	}
+	if got, want := len(probe.tablebase), len(core.space.nodes); got != want {
+		t.Errorf("LoadTablebase got %d positions want %d", got, want)
+	}
+	rows := probe.rows()
+	for _, in := range inputs {
+		probeOut.Reset()
+		err := probe.Probe(in.query)
+		if (err != nil) != in.err {
+			t.Errorf("Probe %q got err %v want err %v", in.query, err, in.err)
+		}
+		if !strings.HasSuffix(probeOut.String(), in.want) {
+			t.Errorf("Probe %q got %q want %q", in.query, probeOut.String(), in.want)
+		}
+		if got := probe.rows(); !slices.Equal(got, rows) {
+			t.Errorf("Probe %q got rows %q after want %q", in.query, got, rows)
+		}
+	}
+	if err := probe.LoadTablebase(strings.NewReader(probe.header() + "\n0\t   k,    ,P   ,KR  \t1\n")); err == nil {
+		t.Errorf("LoadTablebase got no err for missing fields")
+	}
+	if err := probe.LoadTablebase(strings.NewReader("0\t   k,    ,P   ,KR  \t1\t11\tK30-21\n")); err == nil {
+		t.Errorf("LoadTablebase got no err for missing header")
+	}
+	other, err := New(&bytes.Buffer{}, config.Config{EnableDrop: true})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	if err := other.LoadTablebase(bytes.NewReader(buffer.Bytes())); err == nil {
+		t.Errorf("LoadTablebase got no err for different rules")
+	}
+}
+
+func TestProbePromoted(t *testing.T) {
+	cfg := config.Config{Board: " r ,P  ,  k", EnablePromotion: true, EnableDrop: true, EnableDemotion: true}
+	core, err := New(&bytes.Buffer{}, cfg)
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Enumerate()
+	var buffer bytes.Buffer
+	if err := core.WriteSpace(&buffer); err != nil {
+		t.Fatalf("WriteSpace got err %v", err)
+	}
+	cfg.Board = ""
+	probe, err := New(&bytes.Buffer{}, cfg)
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	if err := probe.LoadTablebase(bytes.NewReader(buffer.Bytes())); err != nil {
+		t.Fatalf("LoadTablebase got err %v", err)
+	}
+	if got, want := len(probe.tablebase), len(core.space.nodes); got != want {
+		t.Errorf("LoadTablebase got %d positions want %d", got, want)
+	}
+	for id, n := range core.space.nodes {
+		if one := probe.tablebase[n]; one.value != core.space.values[id] || one.dtm != core.space.dtms[id] {
+			t.Errorf("LoadTablebase %v got %v want %d %d", n, one, core.space.values[id], core.space.dtms[id])
+			break
+		}
+	}
+}
+
+func TestWritePuzzles(t *testing.T) {
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{
+		Board: "  R ,k   , R  ,R  N", PuzzleMoves: 3, PuzzleUnique: true, PuzzleMaterial: "kRNRR"})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Enumerate()
+	var buffer bytes.Buffer
+	if err := core.WritePuzzles(&buffer); err != nil {
+		t.Fatalf("WritePuzzles got err %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buffer.String(), "\n"), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		t.Fatalf("WritePuzzles got no puzzles")
+	}
+	for _, line := range lines {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || fields[2] != "3" || len(strings.Fields(fields[3])) != 5 {
+			t.Errorf("WritePuzzles got line %q", line)
+		}
+	}
+	golden(t, "RNk.puzzles.txt", buffer.Bytes())
+}
+
+func TestMoveOrder(t *testing.T) {
+	inputs := []struct {
+		name   string
+		config config.Config
+	}{{
+		name: "PKR", config: config.Config{Board: "   k,    ,P   ,KR  ,0000,0000"},
+	}, {
+		name: "PrD", config: config.Config{Board: " r  ,P   ,    ,   k,00000,00000", EnableDrop: true, EnablePromotion: true, EnableDemotion: true},
+	}, {
+		name: "QKkCheckmate", config: config.Config{Board: "k   ,    ,K Q ,    ,00000,00000", EnableCheckmate: true},
 	}}
 	for _, in := range inputs {
-		config := config.Config{MaxPrintDepth: -1}
-		b.Run(in.name, func(b *testing.B) {
-			for b.Loop() {
+		for _, order := range moveOrders {
+			outs := []string{}
+			for range 2 {
+				in.config.MaxPrintDepth, in.config.MoveOrder = -1, order
 				var out bytes.Buffer
-				core := New(&out, config)
-				core.board = in.board
+				core, err := New(&out, in.config)
+				if err != nil {
+					t.Fatalf("New %v got err %v", in, err)
+				}
 				core.Solve()
+				if core.nodes == 0 {
+					t.Errorf("Solve %v %s got no nodes", in, order)
+				}
+				outs = append(outs, out.String())
+			}
+			if outs[0] != outs[1] {
+				t.Errorf("Solve %v %s got %q and %q want deterministic", in, order, outs[0], outs[1])
+			}
+		}
+	}
+	if _, err := New(&bytes.Buffer{}, config.Config{MoveOrder: "random"}); err == nil {
+		t.Errorf("New got no err for invalid move order")
+	}
+}
+
+func TestTable(t *testing.T) {
+	inputs := []config.Config{
+		{Board: "   k,    ,P   ,KR  ,0000,0000"},
+		{Board: " r ,P  ,  k,00000,00000", EnableDrop: true, EnablePromotion: true, EnableDemotion: true},
+		{Board: "k   ,    ,K Q ,    ,00000,00000", EnableCheckmate: true},
+		{Board: "  r ,    ,    ,R   ,0000,0000", EnableDrop: true},
+	}
+	for _, in := range inputs {
+		in.MaxPrintDepth = -1
+		core, err := New(&bytes.Buffer{}, in)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		core.Solve()
+		want, _ := core.value(0)
+		buckets := core.table.Stored()
+		var out bytes.Buffer
+		if core, err = New(&out, in); err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		table := &boundedTable{buckets: make([][2]tableEntry, buckets)}
+		core.table, core.decisive = table, map[uint64]bool{}
+		core.Solve()
+		if got, _ := core.value(0); got != want {
+			t.Errorf("Solve %v with %d buckets got %d want %d", in, buckets, got, want)
+		}
+		if table.Evicted() == 0 {
+			t.Errorf("Solve %v with %d buckets got no evictions", in, buckets)
+		}
+		if table.Stored() > 2*buckets {
+			t.Errorf("Solve %v with %d buckets got %d memos", in, buckets, table.Stored())
+		}
+	}
+	for _, buckets := range []int{257, 509} {
+		in := config.Config{Board: "  k,   ,K  ,0000,0000", MaxPrintDepth: -1}
+		core, err := New(&bytes.Buffer{}, in)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		core.table, core.decisive = &boundedTable{buckets: make([][2]tableEntry, buckets)}, map[uint64]bool{}
+		core.Solve()
+		if got, _ := core.value(0); got != 0 {
+			t.Errorf("Solve %v with %d buckets got %d want 0", in, buckets, got)
+		}
+		if core.repeated > 0 && core.found > 0 {
+			t.Errorf("Solve %v with %d buckets stopped after %d passes with %d new wins and losses", in, buckets, core.passes, core.found)
+		}
+	}
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{Board: "   k,    ,P   ,KR  ,0000,0000", MaxPrintDepth: -1, TableSize: 1})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.Solve()
+	if !strings.Contains(out.String(), "\nevicted: ") {
+		t.Errorf("Solve with table size got %q want evicted", out.String())
+	}
+}
+
+func TestDiskTable(t *testing.T) {
+	inputs := []config.Config{
+		{Board: "   k,    ,P   ,KR  ,0000,0000"},
+		{Board: " r ,P  ,  k,00000,00000", EnableDrop: true, EnablePromotion: true, EnableDemotion: true},
+		{Board: "k   ,    ,K Q ,    ,00000,00000", EnableCheckmate: true},
+	}
+	for _, in := range inputs {
+		in.MaxPrintDepth = -1
+		var want bytes.Buffer
+		core, err := New(&want, in)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		core.Solve()
+		in.TableDir = t.TempDir()
+		var got bytes.Buffer
+		if core, err = New(&got, in); err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		table := core.table.(*diskTable)
+		table.limit = 100
+		core.Solve()
+		if got.String() != want.String() {
+			t.Errorf("Solve %v on disk got %q want %q", in, got.String(), want.String())
+		}
+		if len(table.runs) == 0 {
+			t.Errorf("Solve %v on disk got no runs", in)
+		}
+		if err := core.Close(); err != nil {
+			t.Errorf("Close %v got err %v", in, err)
+		}
+		if entries, err := os.ReadDir(in.TableDir); err != nil || len(entries) != 0 {
+			t.Errorf("Close %v left %v, %v", in, entries, err)
+		}
+	}
+	if _, err := New(&bytes.Buffer{}, config.Config{TableDir: filepath.Join(t.TempDir(), "missing")}); err == nil {
+		t.Errorf("New got no err for missing table dir")
+	}
+	in := config.Config{Board: "   k,    ,P   ,KR  ,0000,0000", MaxPrintDepth: -1}
+	want := solveValue(t, in)
+	in.TableDir = t.TempDir()
+	core, err := New(&bytes.Buffer{}, in)
+	if err != nil {
+		t.Fatalf("New %v got err %v", in, err)
+	}
+	table := core.table.(*diskTable)
+	table.limit = 100
+	if err := os.RemoveAll(table.dir); err != nil {
+		t.Fatalf("RemoveAll got err %v", err)
+	}
+	core.Solve()
+	if got, _ := core.value(0); got != want {
+		t.Errorf("Solve %v without table dir got %d want %d", in, got, want)
+	}
+	if err := core.Close(); err == nil {
+		t.Errorf("Close %v got no err without table dir", in)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	inputs := []config.Config{
+		{Board: "   k,    ,P   ,KR  ,0000,0000"},
+		{Board: " r ,P  ,  k,00000,00000", EnableDrop: true, EnablePromotion: true, EnableDemotion: true},
+		{Board: "k   ,    ,K Q ,    ,00000,00000", EnableCheckmate: true},
+		{Board: "   k,    ,P   ,KR  ,0000,0000", TableSize: 1},
+		{Board: " r ,P  ,  k,00000,00000", EnableDrop: true, EnablePromotion: true, EnableDemotion: true, TableSize: 1},
+		{Board: "   k,    ,P   ,KR  ,0000,0000", TableDir: "dir", TableSize: 1},
+		{Board: "   k,    ,P   ,KR  ,0000,0000", MoveOrder: "history"},
+		{Board: "   k,    ,P   ,KR  ,0000,0000", MoveOrder: "killer"},
+	}
+	for _, in := range inputs {
+		in.MaxPrintDepth = -1
+		in.CheckpointFile = filepath.Join(t.TempDir(), "checkpoint")
+		if in.TableDir != "" {
+			in.TableDir = t.TempDir()
+		}
+		var want bytes.Buffer
+		core, err := New(&want, in)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		defer core.Close()
+		core.Solve()
+		file, err := os.Open(in.CheckpointFile)
+		if err != nil {
+			t.Fatalf("Solve %v got no checkpoint: %v", in, err)
+		}
+		defer file.Close()
+		in.CheckpointFile = ""
+		var got bytes.Buffer
+		if core, err = New(&got, in); err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		defer core.Close()
+		if err := core.Resume(file); err != nil {
+			t.Fatalf("Resume %v got err %v", in, err)
+		}
+		if len(core.stack) == 0 {
+			t.Errorf("Resume %v got empty stack", in)
+		}
+		core.Solve()
+		if got.String() != want.String() {
+			t.Errorf("Solve %v after Resume got %q want %q", in, got.String(), want.String())
+		}
+	}
+	var out bytes.Buffer
+	core, err := New(&out, config.Config{Board: "   k,    ,P   ,KR  ", MaxPrintDepth: -1})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	core.stack = []State{{NumMoves: 1}}
+	var checkpoint bytes.Buffer
+	if err := core.writeCheckpoint(&checkpoint); err != nil {
+		t.Fatalf("writeCheckpoint got err %v", err)
+	}
+	for _, in := range []config.Config{
+		{Board: "   k,    ,P   ,K R "},
+		{Board: "   k,    ,P   ,KR  ", EnableDrop: true},
+		{Board: "   k,    ,P   ,KR  ", EnableBlockedKnight: true},
+		{Board: "   k,    ,P   ,KR  ", RulesFile: "testdata/chess.json"},
+		{Board: "   k,    ,P   ,KR  ", MoveOrder: "killer"},
+	} {
+		core, err := New(&bytes.Buffer{}, in)
+		if err != nil {
+			t.Fatalf("New %v got err %v", in, err)
+		}
+		if err := core.Resume(bytes.NewReader(checkpoint.Bytes())); err == nil {
+			t.Errorf("Resume %v got no err for checkpoint of %q", in, core.root)
+		}
+	}
+}
+
+func TestMoveArena(t *testing.T) {
+	core, err := New(&bytes.Buffer{}, config.Config{
+		Board: "k     ,      ,      ,      ,      ,     K,11111,11111", EnableDrop: true, MaxPrintDepth: -1,
+	})
+	if err != nil {
+		t.Fatalf("New got err %v", err)
+	}
+	want := []move.Move{}
+	core.moves(&want, 0)
+	if len(want) <= 100 {
+		t.Fatalf("moves got %d want more than 100", len(want))
+	}
+	core.call(&core.stack)
+	state := &core.stack[0]
+	state.Move, state.Promoted = want[0], core.promoted
+	state.What = core.applyMove(state.Move)
+	core.call(&core.stack)
+	first, second := core.stack[0], core.stack[1]
+	if got := core.arena[first.First : first.First+first.NumMoves]; !slices.Equal(got, want) {
+		t.Errorf("call got moves %v want %v", got, want)
+	}
+	if second.First != len(want) || second.First+second.NumMoves != len(core.arena) {
+		t.Errorf("call got moves from %d to %d want from %d to %d",
+			second.First, second.First+second.NumMoves, len(want), len(core.arena))
+	}
+	core.doReturn(&core.stack)
+	if len(core.arena) != len(want) {
+		t.Errorf("doReturn got %d moves want %d", len(core.arena), len(want))
+	}
+}
+
+func FuzzParse(f *testing.F) {
+	for _, in := range solveInputs {
+		f.Add(in.board)
+	}
+	f.Add("")
+	f.Add("K#.x,X   ,    ,   k,12/0/0/0/0,00010")
+	f.Add(" R  ,   r,    ,   k,0000,0000,+01+13")
+	f.Add("    ,    ,    ,    ,0/0/0/13,0")
+	f.Add("#   ,    ,    ,    ,0,0/0/0/12")
+	f.Add("    ,    ,    ,    ,9/9,0")
+	f.Add("    ,    ,    ,    ,000001,0")
+	f.Fuzz(func(t *testing.T, board string) {
+		core, err := New(&bytes.Buffer{}, config.Config{Board: board, EnableDemotion: true})
+		if err != nil {
+			return
+		}
+		rows := strings.Join(core.rows(), ",")
+		again, err := New(&bytes.Buffer{}, config.Config{Board: rows, EnableDemotion: true})
+		if err != nil {
+			t.Fatalf("New %q from %q got err %v", rows, board, err)
+		}
+		if again.key() != core.key() || strings.Join(again.rows(), ",") != rows {
+			t.Errorf("New %q from %q got %q", rows, board, again.rows())
+		}
+	})
+}
+
+func FuzzMoves(f *testing.F) {
+	for _, in := range solveInputs {
+		f.Add(in.board, !in.disableDrop, in.enableDemotion, in.enableCheckmate)
+	}
+	f.Add(" R  ,P   ,    ,   k,00000,00000", true, true, false)
+	f.Fuzz(func(t *testing.T, board string, enableDrop, enableDemotion, enableCheckmate bool) {
+		core, err := New(&bytes.Buffer{}, config.Config{
+			Board: board, EnablePromotion: true, EnableDrop: enableDrop,
+			EnableDemotion: enableDemotion, EnableCheckmate: enableCheckmate})
+		if err != nil {
+			return
+		}
+		for turn := range 2 {
+			moves := []move.Move{}
+			core.moves(&moves, turn)
+			for _, move := range moves {
+				key := core.key()
+				notation := core.notation(move)
+				promoted := core.promoted
+				what := core.applyMove(move)
+				core.undoMove(move, what, promoted)
+				if core.key() != key {
+					t.Fatalf("%s from %q got %q want %q", notation, board, core.rows(), board)
+				}
 			}
-		})
+		}
+	})
+}
+
+func BenchmarkSolve(b *testing.B) {
+	inputs := []struct {
+		name  string
+		board string
+	}{{
+		name: "Nk", board: "k R ,    ,RR  ,   N,0000,0000",
+	}}
+	for _, in := range inputs {
+		for _, order := range moveOrders {
+			config := config.Config{MaxPrintDepth: -1, Board: in.board, MoveOrder: order}
+			b.Run(in.name+"/"+order, func(b *testing.B) {
+				nodes := 0
+				for b.Loop() {
+					var out bytes.Buffer
+					core, err := New(&out, config)
+					if err != nil {
+						b.Fatalf("New %v got err %v", in, err)
+					}
+					core.Solve()
+					nodes = core.nodes
+				}
+				b.ReportMetric(float64(nodes), "nodes/op")
+			})
+		}
 	}
 }
 
 func TestRunAll(t *testing.T) {
 	inputs := []struct {
 		name    string
+		want    []string
 		configs []config.Config
 	}{{
-		name: "TestRunAll", configs: []config.Config{
+		name: "TestRunAll", want: []string{"0", "1", "0"}, configs: []config.Config{
 			{Board: "   k,    ,P   ,K   "},
 			{Board: "   k,    ,P   ,K   ", EnablePromotion: true},
 			{Board: "   k,    ,P   ,K   ", EnableDrop: true},
@@ -263,9 +1074,25 @@ func TestRunAll(t *testing.T) {
 	}}
 	for _, in := range inputs {
 		var out bytes.Buffer
-		RunAll(&out, in.configs)
-		if err := os.WriteFile(filepath.Join("testdata", in.name+".txt"), out.Bytes(), 0644); err != nil {
-			t.Errorf("TestRunAll %v got err %v", in, err)
+		RunAll(&out, config.Config{}, in.configs)
+		got := []string{}
+		for _, line := range strings.Split(out.String(), "\n") {
+			if res, ok := strings.CutPrefix(line, "overall res: "); ok {
+				got = append(got, res)
+			}
+		}
+		if !slices.Equal(got, in.want) {
+			t.Errorf("RunAll %s got overall res %q want %q", in.name, got, in.want)
+		}
+		golden(t, in.name+".txt", out.Bytes())
+		dir := t.TempDir()
+		var disk bytes.Buffer
+		RunAll(&disk, config.Config{TableDir: dir, TableSize: 1}, in.configs)
+		if disk.String() != out.String() {
+			t.Errorf("RunAll %s with table dir got %s", in.name, diff(out.String(), disk.String()))
+		}
+		if entries, err := os.ReadDir(dir); err != nil || len(entries) > 0 {
+			t.Errorf("RunAll %s left %d entries in the table dir, err %v", in.name, len(entries), err)
 		}
 	}
 }
@@ -0,0 +1,374 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+const (
+	keySize         = 1 + maxHeight*maxWidth + 2*maxHand + 8
+	recordSize      = keySize + 1 + 4
+	blockSize       = 64
+	defaultBufferMB = 64
+	bufferEntrySize = 256
+)
+
+// diskTable keeps the memos in memory up to a limit and then spills them to
+// files of fixed-size records sorted by position. Runs of similar size are
+// merged, so a lookup reads a single block from each of a few runs. The memos
+// are only a cache of the search, so after an I/O error the table keeps the
+// first error for Close, drops the memos it cannot read and stops spilling.
+type diskTable struct {
+	dir   string
+	limit int
+	memos map[node]Memo
+	runs  []*run
+	block [blockSize * recordSize]byte
+	err   error
+}
+
+// run contains a sorted file of records and the first key of each block.
+type run struct {
+	file  *os.File
+	count int
+	index [][keySize]byte
+}
+
+func newDiskTable(dir string, megabytes int) (*diskTable, error) {
+	if megabytes == 0 {
+		megabytes = defaultBufferMB
+	}
+	dir, err := os.MkdirTemp(dir, "memo-")
+	if err != nil {
+		return nil, err
+	}
+	return &diskTable{dir: dir, limit: max(1, megabytes<<20/bufferEntrySize), memos: map[node]Memo{}}, nil
+}
+
+func (t *diskTable) Get(turn int, position Position) (Memo, bool) {
+	if memo, ok := t.memos[node{turn: turn, position: position}]; ok {
+		return memo, true
+	}
+	var key [keySize]byte
+	encodeKey(key[:], turn, position)
+	for i := len(t.runs) - 1; i >= 0; i-- {
+		if memo, ok := t.find(t.runs[i], key); ok {
+			return memo, true
+		}
+	}
+	return Memo{}, false
+}
+
+func (t *diskTable) find(r *run, key [keySize]byte) (Memo, bool) {
+	block, ok := slices.BinarySearchFunc(r.index, key, func(a, b [keySize]byte) int {
+		return bytes.Compare(a[:], b[:])
+	})
+	if !ok {
+		block--
+	}
+	if block < 0 {
+		return Memo{}, false
+	}
+	count := min(blockSize, r.count-block*blockSize)
+	records := t.block[:count*recordSize]
+	if _, err := r.file.ReadAt(records, int64(block*blockSize*recordSize)); err != nil {
+		t.fail(err)
+		return Memo{}, false
+	}
+	for low, high := 0, count; low < high; {
+		mid := (low + high) / 2
+		record := records[mid*recordSize : (mid+1)*recordSize]
+		switch cmp := bytes.Compare(record[:keySize], key[:]); {
+		case cmp == 0:
+			return decodeMemo(record), true
+		case cmp < 0:
+			low = mid + 1
+		default:
+			high = mid
+		}
+	}
+	return Memo{}, false
+}
+
+func (t *diskTable) Put(turn int, position Position, memo Memo, _ int) {
+	t.memos[node{turn: turn, position: position}] = memo
+	if len(t.memos) >= t.limit && t.err == nil {
+		t.flush()
+	}
+}
+
+// fail keeps the first I/O error.
+func (t *diskTable) fail(err error) {
+	if t.err == nil {
+		t.err = fmt.Errorf("table: %w", err)
+	}
+}
+
+// flush writes the memos in memory to a new run and merges the last runs
+// while the previous one is at most twice as large.
+func (t *diskTable) flush() {
+	records := make([][recordSize]byte, 0, len(t.memos))
+	for n, memo := range t.memos {
+		var record [recordSize]byte
+		encodeKey(record[:], n.turn, n.position)
+		encodeMemo(record[:], memo)
+		records = append(records, record)
+	}
+	slices.SortFunc(records, func(a, b [recordSize]byte) int {
+		return bytes.Compare(a[:keySize], b[:keySize])
+	})
+	w, err := t.create()
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	for _, record := range records {
+		w.write(record[:])
+	}
+	r, err := t.close(w, nil)
+	if err != nil {
+		t.fail(err)
+		return
+	}
+	t.runs = append(t.runs, r)
+	clear(t.memos)
+	for n := len(t.runs); n >= 2 && t.runs[n-2].count <= 2*t.runs[n-1].count; n = len(t.runs) {
+		merged, err := t.merge(t.runs[n-2], t.runs[n-1])
+		if err != nil {
+			t.fail(err)
+			return
+		}
+		t.runs = append(t.runs[:n-2], merged)
+	}
+}
+
+// merge merges two runs into a new one, keeping the newer memo of the
+// positions in both, and removes them.
+func (t *diskTable) merge(older, newer *run) (*run, error) {
+	w, err := t.create()
+	if err != nil {
+		return nil, err
+	}
+	a, b := newRunReader(older), newRunReader(newer)
+	for a.ok || b.ok {
+		cmp := -1
+		switch {
+		case !a.ok:
+			cmp = 1
+		case b.ok:
+			cmp = bytes.Compare(a.record[:keySize], b.record[:keySize])
+		}
+		if cmp < 0 {
+			w.write(a.record[:])
+			a.next()
+			continue
+		}
+		w.write(b.record[:])
+		if cmp == 0 {
+			a.next()
+		}
+		b.next()
+	}
+	merged, err := t.close(w, errors.Join(a.err, b.err))
+	if err != nil {
+		return nil, err
+	}
+	t.remove(older)
+	t.remove(newer)
+	return merged, nil
+}
+
+func (t *diskTable) Retain(keep func(Memo) bool) {
+	maps.DeleteFunc(t.memos, func(_ node, memo Memo) bool { return !keep(memo) })
+	runs := t.runs[:0]
+	for _, r := range t.runs {
+		next, err := t.retain(r, keep)
+		t.remove(r)
+		if err != nil {
+			// The memos that cannot be filtered are dropped with the run.
+			t.fail(err)
+			continue
+		}
+		if next.count > 0 {
+			runs = append(runs, next)
+		} else {
+			t.remove(next)
+		}
+	}
+	t.runs = runs
+}
+
+// retain writes the records of the run to keep to a new run.
+func (t *diskTable) retain(r *run, keep func(Memo) bool) (*run, error) {
+	w, err := t.create()
+	if err != nil {
+		return nil, err
+	}
+	reader := newRunReader(r)
+	for ; reader.ok; reader.next() {
+		if keep(decodeMemo(reader.record[:])) {
+			w.write(reader.record[:])
+		}
+	}
+	return t.close(w, reader.err)
+}
+
+// Stored counts the memos in memory and in every run, so a position put again
+// after it was spilled is counted once per copy.
+func (t *diskTable) Stored() int {
+	res := len(t.memos)
+	for _, r := range t.runs {
+		res += r.count
+	}
+	return res
+}
+
+func (t *diskTable) Evicted() int {
+	return 0
+}
+
+// All returns the memos of the runs from the oldest, then the ones in memory,
+// so a position in several runs is last returned with its newest memo.
+func (t *diskTable) All() iter.Seq[tableEntry] {
+	return func(yield func(tableEntry) bool) {
+		for _, r := range t.runs {
+			reader := newRunReader(r)
+			for ; reader.ok; reader.next() {
+				n := decodeKey(reader.record[:])
+				if !yield(tableEntry{position: n.position, memo: decodeMemo(reader.record[:]), turn: int8(n.turn), used: true}) {
+					return
+				}
+			}
+			if reader.err != nil {
+				t.fail(reader.err)
+			}
+		}
+		for n, memo := range t.memos {
+			if !yield(tableEntry{position: n.position, memo: memo, turn: int8(n.turn), used: true}) {
+				return
+			}
+		}
+	}
+}
+
+// Close removes the files and returns the first I/O error of the table.
+func (t *diskTable) Close() error {
+	for _, r := range t.runs {
+		r.file.Close()
+	}
+	t.runs = nil
+	if err := os.RemoveAll(t.dir); err != nil {
+		t.fail(err)
+	}
+	return t.err
+}
+
+func (t *diskTable) create() (*runWriter, error) {
+	file, err := os.CreateTemp(t.dir, "run-")
+	if err != nil {
+		return nil, err
+	}
+	return &runWriter{run: &run{file: file}, writer: bufio.NewWriter(file)}, nil
+}
+
+// close returns the written run, or removes it on the write error or on the
+// given read error.
+func (t *diskTable) close(w *runWriter, err error) (*run, error) {
+	if err == nil {
+		err = w.writer.Flush()
+	}
+	if err != nil {
+		t.remove(w.run)
+		return nil, err
+	}
+	return w.run, nil
+}
+
+func (t *diskTable) remove(r *run) {
+	r.file.Close()
+	if err := os.Remove(r.file.Name()); err != nil {
+		t.fail(err)
+	}
+}
+
+// runWriter writes the records of a run in order.
+type runWriter struct {
+	run    *run
+	writer *bufio.Writer
+}
+
+// write writes a record, where an error is kept by the buffered writer until
+// the run is closed.
+func (w *runWriter) write(record []byte) {
+	if w.run.count%blockSize == 0 {
+		w.run.index = append(w.run.index, [keySize]byte(record))
+	}
+	w.writer.Write(record)
+	w.run.count++
+}
+
+// runReader reads the records of a run in order, up to a read error.
+type runReader struct {
+	reader *bufio.Reader
+	record [recordSize]byte
+	ok     bool
+	err    error
+}
+
+func newRunReader(r *run) *runReader {
+	res := &runReader{reader: bufio.NewReader(io.NewSectionReader(r.file, 0, int64(r.count*recordSize)))}
+	res.next()
+	return res
+}
+
+func (r *runReader) next() {
+	_, err := io.ReadFull(r.reader, r.record[:])
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	r.ok = err == nil
+}
+
+func encodeKey(key []byte, turn int, position Position) {
+	key[0] = byte(turn)
+	i := 1
+	for _, row := range position.Board {
+		i += copy(key[i:], row[:])
+	}
+	for _, hand := range position.Hands {
+		i += copy(key[i:], hand[:])
+	}
+	binary.BigEndian.PutUint64(key[i:], position.Promoted)
+}
+
+func decodeKey(key []byte) node {
+	res := node{turn: int(key[0])}
+	i := 1
+	for row := range res.position.Board {
+		i += copy(res.position.Board[row][:], key[i:])
+	}
+	for hand := range res.position.Hands {
+		i += copy(res.position.Hands[hand][:], key[i:])
+	}
+	res.position.Promoted = binary.BigEndian.Uint64(key[i:])
+	return res
+}
+
+func encodeMemo(record []byte, memo Memo) {
+	record[keySize] = byte(int8(memo.Value))
+	binary.BigEndian.PutUint32(record[keySize+1:], uint32(memo.Move))
+}
+
+func decodeMemo(record []byte) Memo {
+	return Memo{Value: int(int8(record[keySize])), Move: move.Move(binary.BigEndian.Uint32(record[keySize+1:]))}
+}
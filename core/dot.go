@@ -0,0 +1,101 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+type node struct {
+	turn     int
+	position Position
+}
+
+type graph struct {
+	writer io.Writer
+	ids    map[node]int
+	onPath map[node]bool
+	cut    map[node]bool
+}
+
+var valueColors = map[int]string{1: "palegreen", 0: "lightgray", -1: "lightpink"}
+
+// WriteDOT writes the solved game graph in Graphviz DOT format.
+func (c *Core) WriteDOT(writer io.Writer) {
+	g := &graph{writer: writer, ids: map[node]int{}, onPath: map[node]bool{}, cut: map[node]bool{}}
+	fmt.Fprintln(writer, "digraph {")
+	fmt.Fprintln(writer, `  node [shape=box style=filled fontname="monospace"];`)
+	c.dot(g, 0, 0)
+	fmt.Fprintln(writer, "}")
+}
+
+func (c *Core) dot(g *graph, depth, turn int) int {
+	current := node{turn: turn, position: c.key()}
+	id, ok := g.ids[current]
+	if !ok {
+		id = len(g.ids)
+		g.ids[current] = id
+		value, ok := c.value(turn)
+		c.dotNode(g.writer, id, turn, value, ok)
+	}
+	if c.config.DOTMaxDepth != 0 && depth >= c.config.DOTMaxDepth {
+		g.cut[current] = true
+		return id
+	}
+	delete(g.cut, current)
+	g.onPath[current] = true
+	defer delete(g.onPath, current)
+	moves := []move.Move{}
+	c.moves(&moves, turn)
+	if value, ok := c.value(turn); c.config.DOTBestOnly && ok && value == 1 {
+		moves = []move.Move{c.memo((turn + 1) % 2).Move}
+	}
+	for _, move := range moves {
+		notation := c.notation(move)
+		promoted := c.promoted
+		what := c.applyMove(move)
+		next := node{turn: (turn + 1) % 2, position: c.key()}
+		nextID, seen := g.ids[next]
+		attributes := ""
+		switch {
+		case g.onPath[next]:
+			attributes = " style=dashed color=blue constraint=false"
+		case seen && !g.cut[next]:
+		case move.IsKing():
+			nextID = len(g.ids)
+			g.ids[next] = nextID
+			c.dotNode(g.writer, nextID, next.turn, -1, true)
+		default:
+			nextID = c.dot(g, depth+1, next.turn)
+		}
+		c.undoMove(move, what, promoted)
+		fmt.Fprintf(g.writer, "  n%d -> n%d [label=%q%s];\n", id, nextID, notation, attributes)
+	}
+	return id
+}
+
+func (c *Core) dotNode(writer io.Writer, id, turn, value int, ok bool) {
+	lines := []string{}
+	for _, row := range c.rows() {
+		lines = append(lines, "|"+row+"|")
+	}
+	lines = append(lines, fmt.Sprintf("turn: %d", turn))
+	color := "white"
+	if ok {
+		lines = append(lines, fmt.Sprintf("res: %d", value))
+		color = valueColors[value]
+	} else {
+		lines = append(lines, "res: ?")
+	}
+	fmt.Fprintf(writer, "  n%d [label=\"%s\\l\" fillcolor=%s];\n", id, strings.Join(lines, `\l`), color)
+}
+
+func (c *Core) value(turn int) (int, bool) {
+	memo, ok := c.table.Get((turn+1)%2, c.key())
+	if !ok {
+		return 0, false
+	}
+	return -memo.Value, true
+}
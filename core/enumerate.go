@@ -0,0 +1,283 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+const (
+	notTerminal = iota
+	kingCapture
+	staleMate
+	checkMate
+)
+
+// space contains the reachable game graph and its retrograde solution.
+type space struct {
+	nodes     []node
+	ids       map[node]int
+	next      [][]int32
+	terminals []int
+	values    []int
+	dtms      []int
+	solved    []bool
+}
+
+// Enumerate enumerates and solves all positions reachable from the board.
+func (c *Core) Enumerate() {
+	c.space = c.enumerate()
+	c.space.retrograde(c.config.StaleMate.Value())
+	c.report()
+}
+
+func (c *Core) enumerate() *space {
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	s := &space{ids: map[node]int{}}
+	s.add(node{turn: 0, position: c.key()})
+	moves := []move.Move{}
+	for id := 0; id < len(s.nodes); id++ {
+		current := s.nodes[id]
+		c.setKey(current.position)
+		moves = moves[:0]
+		c.moves(&moves, current.turn)
+		if len(moves) == 0 {
+			s.terminals[id] = staleMate
+			if c.config.EnableCheckmate && c.inCheck(current.turn) {
+				s.terminals[id] = checkMate
+			}
+			continue
+		}
+		if moves[0].IsKing() {
+			s.terminals[id] = kingCapture
+			continue
+		}
+		next := make([]int32, 0, len(moves))
+		for _, move := range moves {
+			what := c.applyMove(move)
+			next = append(next, int32(s.add(node{turn: (current.turn + 1) % 2, position: c.key()})))
+			c.undoMove(move, what, current.position.Promoted)
+		}
+		s.next[id] = next
+	}
+	return s
+}
+
+func (s *space) add(n node) int {
+	if id, ok := s.ids[n]; ok {
+		return id
+	}
+	id := len(s.nodes)
+	s.ids[n] = id
+	s.nodes = append(s.nodes, n)
+	s.next = append(s.next, nil)
+	s.terminals = append(s.terminals, notTerminal)
+	return id
+}
+
+// retrograde solves the graph backwards from the terminal positions,
+// computing the value for the side to move and the distance to mate in plies.
+// Positions never solved are draws by repetition.
+func (s *space) retrograde(staleMateValue int) {
+	s.values = make([]int, len(s.nodes))
+	s.dtms = make([]int, len(s.nodes))
+	s.solved = make([]bool, len(s.nodes))
+	prev := make([][]int32, len(s.nodes))
+	remaining := make([]int, len(s.nodes))
+	layers := [][]int{{}, {}}
+	for id, next := range s.next {
+		remaining[id] = len(next)
+		for _, one := range next {
+			prev[one] = append(prev[one], int32(id))
+		}
+		switch s.terminals[id] {
+		case kingCapture:
+			s.values[id], s.dtms[id] = 1, 1
+		case staleMate:
+			s.values[id] = staleMateValue
+		case checkMate:
+			s.values[id] = -1
+		default:
+			continue
+		}
+		s.solved[id] = true
+		layers[s.dtms[id]] = append(layers[s.dtms[id]], id)
+	}
+	for dtm := 0; dtm < len(layers); dtm++ {
+		for _, id := range layers[dtm] {
+			if s.values[id] == 0 {
+				continue
+			}
+			for _, one := range prev[id] {
+				if s.solved[one] {
+					continue
+				}
+				remaining[one]--
+				if s.values[id] == 1 && remaining[one] > 0 {
+					continue
+				}
+				s.values[one], s.dtms[one], s.solved[one] = -s.values[id], dtm+1, true
+				if len(layers) == dtm+1 {
+					layers = append(layers, nil)
+				}
+				layers[dtm+1] = append(layers[dtm+1], int(one))
+			}
+		}
+	}
+}
+
+func (c *Core) report() {
+	s := c.space
+	fmt.Fprintf(c.writer, "\npositions: %d\n", len(s.nodes))
+	turns := [2]int{}
+	terminals := [4]int{}
+	values := map[int]int{}
+	edges, branching, minBranching, maxBranching, maxDTM := 0, 0, 0, 0, 0
+	for id, n := range s.nodes {
+		turns[n.turn]++
+		terminals[s.terminals[id]]++
+		values[s.values[id]]++
+		maxDTM = max(maxDTM, s.dtms[id])
+		if s.terminals[id] != notTerminal {
+			continue
+		}
+		edges += len(s.next[id])
+		if branching == 0 || len(s.next[id]) < minBranching {
+			minBranching = len(s.next[id])
+		}
+		maxBranching = max(maxBranching, len(s.next[id]))
+		branching++
+	}
+	for turn, count := range turns {
+		fmt.Fprintf(c.writer, "positions[%d]: %d\n", turn, count)
+	}
+	fmt.Fprintf(c.writer, "terminal king capture: %d\n", terminals[kingCapture])
+	fmt.Fprintf(c.writer, "terminal stalemate: %d\n", terminals[staleMate])
+	fmt.Fprintf(c.writer, "terminal checkmate: %d\n", terminals[checkMate])
+	fmt.Fprintf(c.writer, "edges: %d\n", edges)
+	mean := 0.0
+	if branching > 0 {
+		mean = float64(edges) / float64(branching)
+	}
+	fmt.Fprintf(c.writer, "branching: min %d mean %.2f max %d\n", minBranching, mean, maxBranching)
+	fmt.Fprintf(c.writer, "win: %d\n", values[1])
+	fmt.Fprintf(c.writer, "draw: %d\n", values[0])
+	fmt.Fprintf(c.writer, "loss: %d\n", values[-1])
+	fmt.Fprintf(c.writer, "max dtm: %d\n", maxDTM)
+	fmt.Fprintf(c.writer, "overall res: %d\n", s.values[0])
+	fmt.Fprintf(c.writer, "overall dtm: %d\n", s.dtms[0])
+}
+
+// WriteSpace writes a header with the rules, then the enumerated positions,
+// one per line, as the turn, the board in --board format, the value for the
+// side to move, the distance to mate in plies and the best move, separated
+// by tabs.
+func (c *Core) WriteSpace(writer io.Writer) error {
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	if _, err := fmt.Fprintln(writer, c.header()); err != nil {
+		return err
+	}
+	for id, n := range c.space.nodes {
+		c.setKey(n.position)
+		best := "-"
+		if choices := c.choices(n.turn); len(choices) > 0 {
+			best = choices[0].notation
+		}
+		if _, err := fmt.Fprintf(writer, "%d\t%s\t%d\t%d\t%s\n",
+			n.turn, strings.Join(c.rows(), ","), c.space.values[id], c.space.dtms[id], best); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// choice contains a move with its value and distance to mate in plies for
+// the side to move, unknown for a move into a position that was not
+// enumerated.
+type choice struct {
+	move     move.Move
+	notation string
+	next     int
+	value    int
+	dtm      int
+	unknown  bool
+}
+
+// choices returns the moves from the current board, best first: wins by
+// shortest mate, then draws, then losses by longest mate, then the unknown
+// moves into positions that were not enumerated, after a king capture was
+// available.
+func (c *Core) choices(turn int) []choice {
+	res := []choice{}
+	moves := []move.Move{}
+	c.moves(&moves, turn)
+	for _, move := range moves {
+		one := choice{move: move, notation: c.notation(move), next: -1, value: 1, dtm: 1}
+		if !move.IsKing() {
+			promoted := c.promoted
+			what := c.applyMove(move)
+			next, ok := c.space.ids[node{turn: (turn + 1) % 2, position: c.key()}]
+			c.undoMove(move, what, promoted)
+			if !ok {
+				one.value, one.dtm, one.unknown = 0, 0, true
+				res = append(res, one)
+				continue
+			}
+			one.next = next
+			one.value, one.dtm = -c.space.values[one.next], 0
+			if one.value != 0 {
+				one.dtm = c.space.dtms[one.next] + 1
+			}
+		}
+		res = append(res, one)
+	}
+	slices.SortStableFunc(res, func(a, b choice) int {
+		if a.unknown != b.unknown {
+			if a.unknown {
+				return 1
+			}
+			return -1
+		}
+		if a.value != b.value {
+			return cmp.Compare(b.value, a.value)
+		}
+		return a.value * cmp.Compare(a.dtm, b.dtm)
+	})
+	return res
+}
+
+var marks = map[int]string{1: "W", 0: "D", -1: "L"}
+
+func (one choice) String() string {
+	if one.unknown {
+		return fmt.Sprintf("? %s res: ? dtm: ?", one.notation)
+	}
+	dtm := "-"
+	if one.value != 0 {
+		dtm = fmt.Sprint(one.dtm)
+	}
+	return fmt.Sprintf("%s %s res: %d dtm: %s", marks[one.value], one.notation, one.value, dtm)
+}
+
+func (c *Core) solveSpace() {
+	if c.space == nil {
+		c.space = c.enumerate()
+		c.space.retrograde(c.config.StaleMate.Value())
+	}
+}
+
+// Analyze prints every move from the board with its value and distance to
+// mate in plies, best first, enumerating the state space if needed.
+func (c *Core) Analyze() {
+	c.solveSpace()
+	fmt.Fprintf(c.writer, "\nanalyze\n")
+	for _, one := range c.choices(0) {
+		fmt.Fprintln(c.writer, one)
+	}
+}
@@ -0,0 +1,132 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// proof contains a move of the proof tree with the replies to it.
+type proof struct {
+	choice
+	children   []*proof
+	transposed bool
+	cut        bool
+}
+
+// Explain prints the proof tree of the move from the board, with the best
+// move for the side that gets the better result and every move for the other
+// side, enumerating the state space if needed.
+func (c *Core) Explain(notation string) error {
+	p, err := c.explain(notation)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.writer, "\nexplain\n")
+	c.printProof(p, 0)
+	return nil
+}
+
+// WriteExplainDOT writes the proof tree of the move from the board in
+// Graphviz DOT format.
+func (c *Core) WriteExplainDOT(writer io.Writer, notation string) error {
+	p, err := c.explain(notation)
+	if err != nil {
+		return err
+	}
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	g := &graph{writer: writer, ids: map[node]int{}}
+	fmt.Fprintln(writer, "digraph {")
+	fmt.Fprintln(writer, `  node [shape=box style=filled fontname="monospace"];`)
+	root := node{turn: 0, position: c.key()}
+	g.ids[root] = 0
+	c.dotNode(writer, 0, 0, c.space.values[0], true)
+	c.dotProof(g, p, 0, root)
+	fmt.Fprintln(writer, "}")
+	return nil
+}
+
+func (c *Core) explain(notation string) (*proof, error) {
+	c.solveSpace()
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	choices := c.choices(0)
+	notations := []string{}
+	for _, one := range choices {
+		notations = append(notations, one.notation)
+	}
+	for _, one := range choices {
+		if one.notation != notation {
+			continue
+		}
+		prover := 1
+		if one.value == 1 {
+			prover = 0
+		}
+		res := &proof{choice: one}
+		c.prove(res, 1, prover, 1, map[int]bool{})
+		return res, nil
+	}
+	return nil, fmt.Errorf("move %q is not legal, want one of %v", notation, notations)
+}
+
+func (c *Core) prove(parent *proof, turn, prover, depth int, seen map[int]bool) {
+	if parent.next < 0 || c.space.terminals[parent.next] == staleMate || c.space.terminals[parent.next] == checkMate {
+		return
+	}
+	if seen[parent.next] {
+		parent.transposed = true
+		return
+	}
+	if c.config.ExplainMaxDepth != 0 && depth >= c.config.ExplainMaxDepth {
+		parent.cut = true
+		return
+	}
+	seen[parent.next] = true
+	c.setKey(c.space.nodes[parent.next].position)
+	choices := c.choices(turn)
+	if turn == prover && len(choices) > 0 {
+		choices = choices[:1]
+	}
+	for _, one := range choices {
+		child := &proof{choice: one}
+		parent.children = append(parent.children, child)
+		c.prove(child, (turn+1)%2, prover, depth+1, seen)
+	}
+}
+
+func (c *Core) printProof(p *proof, depth int) {
+	suffix := ""
+	if p.transposed {
+		suffix = " (transposition)"
+	} else if p.cut {
+		suffix = " ..."
+	}
+	fmt.Fprintf(c.writer, "%s%s%s\n", strings.Repeat("  ", depth), p.choice, suffix)
+	for _, child := range p.children {
+		c.printProof(child, depth+1)
+	}
+}
+
+func (c *Core) dotProof(g *graph, p *proof, id int, current node) {
+	c.setKey(current.position)
+	c.applyMove(p.move)
+	next := node{turn: (current.turn + 1) % 2, position: c.key()}
+	nextID, seen := g.ids[next]
+	if !seen {
+		nextID = len(g.ids)
+		g.ids[next] = nextID
+		c.dotNode(g.writer, nextID, next.turn, -p.value, !p.unknown)
+	}
+	attributes := ""
+	if p.transposed {
+		attributes = " style=dashed color=blue constraint=false"
+	} else if p.cut {
+		attributes = " style=dotted"
+	}
+	fmt.Fprintf(g.writer, "  n%d -> n%d [label=%q%s];\n", id, nextID, p.notation, attributes)
+	for _, child := range p.children {
+		c.dotProof(g, child, nextID, next)
+	}
+}
@@ -0,0 +1,27 @@
+package core
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/kssilveira/chess-solver/animation"
+)
+
+// WriteGIF writes the last shown game as an animated GIF.
+func (c *Core) WriteGIF(writer io.Writer) error {
+	return animation.Write(writer, c.frames, c.config.GIFDelay)
+}
+
+func (c *Core) frame(caption ...string) animation.Frame {
+	res := animation.Frame{Caption: caption}
+	res.Board = c.rows()[:c.height]
+	for turn, pieces := range c.deadXY {
+		if c.handSize > 0 {
+			res.Hands = append(res.Hands, fmt.Sprintf("%s: %s", pieces[:c.handSize], c.handRow(turn, c.handSize)))
+		}
+	}
+	if c.promoted != 0 {
+		res.Hands = append(res.Hands, "promoted: "+c.promotedRow())
+	}
+	return res
+}
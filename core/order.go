@@ -0,0 +1,159 @@
+package core
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+// MoveOrderer orders the moves searched by Solve.
+type MoveOrderer interface {
+	// Order orders the moves of the position at depth, given in static order.
+	Order(moves []move.Move, turn, depth int)
+	// Cutoff records the winning move that ended the search of the position at depth.
+	Cutoff(move move.Move, turn, depth int)
+}
+
+var moveOrders = []string{"static", "history", "killer", "memo"}
+
+func (c *Core) newMoveOrderer(name string) (MoveOrderer, error) {
+	switch name {
+	case "", "static":
+		return staticOrder{}, nil
+	case "history":
+		return &historyOrder{scores: [2]map[move.Move]int{{}, {}}}, nil
+	case "killer":
+		return &killerOrder{}, nil
+	case "memo":
+		return &memoOrder{core: c}, nil
+	}
+	return nil, fmt.Errorf("move order %q must be one of %v", name, moveOrders)
+}
+
+// staticRank orders king captures, then captures, then drops, then the other moves.
+func staticRank(move move.Move) int {
+	switch {
+	case move.IsKing():
+		return 0
+	case move.IsCapture():
+		return 1
+	case move.IsDrop():
+		return 2
+	}
+	return 3
+}
+
+func (c *Core) sort(moves []move.Move) {
+	slices.SortStableFunc(moves, func(i, j move.Move) int {
+		return cmp.Compare(staticRank(i), staticRank(j))
+	})
+}
+
+type rankedMove struct {
+	move move.Move
+	rank int
+}
+
+// sortByRank sorts the moves by increasing rank, keeping the order of equal ranks.
+func sortByRank(moves []move.Move, rank func(move.Move) int) {
+	ranked := make([]rankedMove, 0, len(moves))
+	for _, move := range moves {
+		ranked = append(ranked, rankedMove{move: move, rank: rank(move)})
+	}
+	slices.SortStableFunc(ranked, func(i, j rankedMove) int {
+		return cmp.Compare(i.rank, j.rank)
+	})
+	for i, one := range ranked {
+		moves[i] = one.move
+	}
+}
+
+// staticOrder keeps the static order.
+type staticOrder struct{}
+
+func (staticOrder) Order([]move.Move, int, int) {}
+
+func (staticOrder) Cutoff(move.Move, int, int) {}
+
+// historyOrder searches first the moves that ended more searches, after king captures.
+type historyOrder struct {
+	scores [2]map[move.Move]int
+}
+
+func (h *historyOrder) Order(moves []move.Move, turn, _ int) {
+	sortByRank(moves, func(move move.Move) int {
+		if move.IsKing() {
+			return -1 << 30
+		}
+		return -h.scores[turn][move]
+	})
+}
+
+func (h *historyOrder) Cutoff(move move.Move, turn, _ int) {
+	h.scores[turn][move]++
+}
+
+// killerOrder searches first the last two moves that ended a search at the
+// same depth, after king captures.
+type killerOrder struct {
+	killers [][2]move.Move
+}
+
+func (k *killerOrder) Order(moves []move.Move, _, depth int) {
+	if depth >= len(k.killers) {
+		return
+	}
+	killers := k.killers[depth]
+	sortByRank(moves, func(move move.Move) int {
+		switch {
+		case move.IsKing():
+			return 0
+		case move == killers[0]:
+			return 1
+		case move == killers[1]:
+			return 2
+		}
+		return 3
+	})
+}
+
+func (k *killerOrder) Cutoff(killer move.Move, _, depth int) {
+	for depth >= len(k.killers) {
+		k.killers = append(k.killers, [2]move.Move{})
+	}
+	if k.killers[depth][0] != killer {
+		k.killers[depth] = [2]move.Move{killer, k.killers[depth][0]}
+	}
+}
+
+// memoOrder searches first the moves into positions already solved as wins
+// for the side to move, then draws, then unsolved positions, then losses.
+type memoOrder struct {
+	core *Core
+}
+
+func (m *memoOrder) Order(moves []move.Move, turn, _ int) {
+	c := m.core
+	sortByRank(moves, func(move move.Move) int {
+		if move.IsKing() {
+			return 0
+		}
+		promoted := c.promoted
+		what := c.applyMove(move)
+		memo, ok := c.table.Get(turn, c.key())
+		c.undoMove(move, what, promoted)
+		switch {
+		case !ok:
+			return 3
+		case memo.Value == 1:
+			return 1
+		case memo.Value == -1:
+			return 4
+		}
+		return 2
+	})
+}
+
+func (m *memoOrder) Cutoff(move.Move, int, int) {}
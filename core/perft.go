@@ -0,0 +1,55 @@
+package core
+
+import (
+	"fmt"
+
+	"github.com/kssilveira/chess-solver/move"
+)
+
+// Perft prints and returns the number of move sequences of the given depth
+// from the board, divided by the first move. A king capture ends the game.
+func (c *Core) Perft(depth int) int {
+	fmt.Fprintf(c.writer, "\nperft: %d\n", depth)
+	if depth == 0 {
+		fmt.Fprintf(c.writer, "nodes: 1\n")
+		return 1
+	}
+	moves := []move.Move{}
+	c.moves(&moves, 0)
+	res := 0
+	for _, move := range moves {
+		notation := c.notation(move)
+		count := c.perftMove(move, depth, 0)
+		fmt.Fprintf(c.writer, "%s: %d\n", notation, count)
+		res += count
+	}
+	fmt.Fprintf(c.writer, "nodes: %d\n", res)
+	return res
+}
+
+func (c *Core) perft(depth, turn int) int {
+	if depth == 0 {
+		return 1
+	}
+	moves := []move.Move{}
+	c.moves(&moves, turn)
+	res := 0
+	for _, move := range moves {
+		res += c.perftMove(move, depth, turn)
+	}
+	return res
+}
+
+func (c *Core) perftMove(move move.Move, depth, turn int) int {
+	if move.IsKing() {
+		if depth == 1 {
+			return 1
+		}
+		return 0
+	}
+	promoted := c.promoted
+	what := c.applyMove(move)
+	res := c.perft(depth-1, (turn+1)%2)
+	c.undoMove(move, what, promoted)
+	return res
+}
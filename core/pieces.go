@@ -0,0 +1,95 @@
+package core
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/kssilveira/chess-solver/config"
+	"github.com/kssilveira/chess-solver/rules"
+)
+
+const maxPromotions = 7
+
+const (
+	wall    = '#'
+	hole    = '.'
+	neutral = -2
+)
+
+type delta struct {
+	x, y        int
+	slide       bool
+	moveOnly    bool
+	captureOnly bool
+	leg         bool
+	legX, legY  int
+}
+
+type pieces struct {
+	colors     map[byte]int
+	vectors    map[byte][]delta
+	kings      map[byte]bool
+	promos     map[byte][]byte
+	undoPromos map[byte]byte
+	deadX      map[byte]int
+	deadY      map[byte]int
+	deadXY     [][]byte
+}
+
+func newPieces(rules rules.Rules, config config.Config) (pieces, error) {
+	res := pieces{
+		colors: map[byte]int{' ': -1, wall: neutral, hole: neutral}, vectors: map[byte][]delta{}, kings: map[byte]bool{},
+		promos: map[byte][]byte{}, undoPromos: map[byte]byte{},
+		deadX: map[byte]int{}, deadY: map[byte]int{}, deadXY: [][]byte{{}, {}},
+	}
+	for turn := range 2 {
+		for _, piece := range rules.Pieces {
+			letter := color(piece.Letter[0], turn)
+			res.colors[letter] = turn
+			res.kings[letter] = piece.Royal
+			for _, vector := range piece.Moves {
+				one := delta{
+					x: vector.X, y: vector.Y, slide: vector.Slide,
+					moveOnly: vector.MoveOnly, captureOnly: vector.CaptureOnly,
+				}
+				if len(vector.Leg) == 2 {
+					one.leg, one.legX, one.legY = true, vector.Leg[0], vector.Leg[1]
+				}
+				if piece.Mirror && turn == 1 {
+					one.x, one.legX = -one.x, -one.legX
+				}
+				res.vectors[letter] = append(res.vectors[letter], one)
+			}
+			promotions := piece.Promotions
+			if config.EnableQueenPromotion {
+				promotions = append(slices.Clip(promotions), piece.QueenPromotions...)
+			}
+			for _, promotion := range promotions {
+				target := color(promotion[0], turn)
+				res.promos[letter] = append(res.promos[letter], target)
+				res.undoPromos[target] = letter
+			}
+			if len(res.promos[letter]) > maxPromotions {
+				return pieces{}, fmt.Errorf("piece %q has more than %d promotions", piece.Letter, maxPromotions)
+			}
+			if !piece.Hand {
+				continue
+			}
+			res.deadX[color(letter, 1-turn)] = turn
+			res.deadY[color(letter, 1-turn)] = len(res.deadXY[turn])
+			res.deadXY[turn] = append(res.deadXY[turn], letter)
+		}
+	}
+	if len(res.deadXY[0]) > maxHand {
+		return pieces{}, fmt.Errorf("rules have %d hand pieces, want at most %d", len(res.deadXY[0]), maxHand)
+	}
+	return res, nil
+}
+
+func color(letter byte, turn int) byte {
+	if turn == 0 {
+		return strings.ToUpper(string(letter))[0]
+	}
+	return strings.ToLower(string(letter))[0]
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// entry contains a tablebase position result.
+type entry struct {
+	value int
+	dtm   int
+	move  string
+}
+
+// header returns the first line written by WriteSpace, with the rules the
+// values depend on.
+func (c *Core) header() string {
+	rules := c.game()
+	rules.Root, rules.MoveOrder = "", ""
+	// The game only contains strings, numbers and booleans, which always
+	// marshal.
+	data, _ := json.Marshal(rules)
+	return "#\t" + string(data)
+}
+
+// LoadTablebase loads the positions written by WriteSpace with the same rules.
+func (c *Core) LoadTablebase(reader io.Reader) error {
+	board, hands, handSize, promoted, width, height := c.board, c.hands, c.handSize, c.promoted, c.width, c.height
+	defer func() {
+		c.board, c.hands, c.handSize, c.promoted, c.width, c.height = board, hands, handSize, promoted, width, height
+	}()
+	c.tablebase = map[node]entry{}
+	scanner := bufio.NewScanner(reader)
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("tablebase has no header")
+	}
+	if header := scanner.Text(); header != c.header() {
+		return fmt.Errorf("tablebase header %q does not match the rules %q", header, c.header())
+	}
+	for line := 2; scanner.Scan(); line++ {
+		fields := strings.Split(scanner.Text(), "\t")
+		if len(fields) != 5 {
+			return fmt.Errorf("tablebase line %d has %d fields, want 5", line, len(fields))
+		}
+		turn, err := c.parseQuery(fields[0] + "\t" + fields[1])
+		if err != nil {
+			return fmt.Errorf("tablebase line %d: %v", line, err)
+		}
+		value, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return fmt.Errorf("tablebase line %d has invalid value %q", line, fields[2])
+		}
+		dtm, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("tablebase line %d has invalid dtm %q", line, fields[3])
+		}
+		current := node{turn: turn, position: c.key()}
+		if _, ok := c.tablebase[current]; !ok {
+			c.tablebase[current] = entry{value: value, dtm: dtm, move: fields[4]}
+		}
+	}
+	return scanner.Err()
+}
+
+// Probe prints the value, distance to mate in plies and best move of the
+// query, as a board in --board format optionally preceded by the turn and
+// a tab, from the loaded tablebase.
+func (c *Core) Probe(query string) error {
+	board, hands, handSize, promoted, width, height := c.board, c.hands, c.handSize, c.promoted, c.width, c.height
+	defer func() {
+		c.board, c.hands, c.handSize, c.promoted, c.width, c.height = board, hands, handSize, promoted, width, height
+	}()
+	turn, err := c.parseQuery(query)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.writer, "\nprobe: %s\n", strings.Join(c.rows(), ","))
+	fmt.Fprintf(c.writer, "turn: %d\n", turn)
+	one, ok := c.tablebase[node{turn: turn, position: c.key()}]
+	if !ok {
+		fmt.Fprintf(c.writer, "res: ?\n")
+		return nil
+	}
+	fmt.Fprintf(c.writer, "res: %d\n", one.value)
+	fmt.Fprintf(c.writer, "dtm: %d\n", one.dtm)
+	fmt.Fprintf(c.writer, "move: %s\n", one.move)
+	return nil
+}
+
+func (c *Core) parseQuery(query string) (int, error) {
+	turn := 0
+	if before, after, ok := strings.Cut(query, "\t"); ok {
+		var err error
+		if turn, err = strconv.Atoi(before); err != nil || turn < 0 || turn > 1 {
+			return 0, fmt.Errorf("query %q has invalid turn %q", query, before)
+		}
+		query = after
+	}
+	if err := c.parse(query); err != nil {
+		return 0, err
+	}
+	return turn, nil
+}
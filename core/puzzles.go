@@ -0,0 +1,93 @@
+package core
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// WritePuzzles writes the enumerated positions won by the side to move that
+// match the puzzle options, one per line as the turn, the board, the
+// difficulty as the number of moves of the winning side and the solution
+// line, separated by tabs.
+func (c *Core) WritePuzzles(writer io.Writer) error {
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	for id, n := range c.space.nodes {
+		if c.space.values[id] != 1 || c.space.terminals[id] == kingCapture {
+			continue
+		}
+		moves := (c.space.dtms[id] + 1) / 2
+		if c.config.PuzzleMoves != 0 && moves != c.config.PuzzleMoves {
+			continue
+		}
+		c.setKey(n.position)
+		if !c.isPuzzle(n.turn) {
+			continue
+		}
+		rows := strings.Join(c.rows(), ",")
+		line := strings.Join(c.line(n.turn), " ")
+		if _, err := fmt.Fprintf(writer, "%d\t%s\t%d\t%s\n", n.turn, rows, moves, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Core) isPuzzle(turn int) bool {
+	if c.config.PuzzleMaterial != "" && c.material() != sortLetters(c.config.PuzzleMaterial) {
+		return false
+	}
+	if c.config.PuzzleHands != "" && c.handRow(0, len(c.deadXY[0]))+","+c.handRow(1, len(c.deadXY[1])) != c.config.PuzzleHands {
+		return false
+	}
+	if !c.config.PuzzleUnique {
+		return true
+	}
+	wins := 0
+	for _, one := range c.choices(turn) {
+		if one.value == 1 {
+			wins++
+		}
+	}
+	return wins == 1
+}
+
+func (c *Core) material() string {
+	res := []byte{}
+	for i := 0; i < c.height; i++ {
+		for j := 0; j < c.width; j++ {
+			if c.colors[c.board[i][j]] >= 0 {
+				res = append(res, c.board[i][j])
+			}
+		}
+	}
+	return sortLetters(string(res))
+}
+
+func sortLetters(letters string) string {
+	res := []byte(letters)
+	slices.Sort(res)
+	return string(res)
+}
+
+// line returns the best play from the current board until the game ends.
+func (c *Core) line(turn int) []string {
+	board, hands, promoted := c.board, c.hands, c.promoted
+	defer func() { c.board, c.hands, c.promoted = board, hands, promoted }()
+	res := []string{}
+	for {
+		choices := c.choices(turn)
+		if len(choices) == 0 {
+			return res
+		}
+		best := choices[0]
+		res = append(res, best.notation)
+		if best.next < 0 || best.value == 0 {
+			return res
+		}
+		c.setKey(c.space.nodes[best.next].position)
+		turn = (turn + 1) % 2
+	}
+}
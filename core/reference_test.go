@@ -0,0 +1,186 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand/v2"
+	"strings"
+	"testing"
+
+	"github.com/kssilveira/chess-solver/config"
+	"github.com/kssilveira/chess-solver/move"
+)
+
+// reference is a deliberately simple solver: plain recursive minimax where a
+// position repeated on the current path is a draw. Only wins, losses and the
+// draws that do not depend on a repetition of a position above them on the
+// path are memoized, since a win or loss never reaches a repeated position.
+type reference struct {
+	core   *Core
+	path   map[node]int
+	memo   map[node]int
+	nodes  int
+	budget int
+}
+
+// errBudget is the panic value when the reference exceeds its node budget.
+var errBudget = fmt.Errorf("reference node budget exceeded")
+
+func newReference(core *Core, budget int) *reference {
+	return &reference{core: core, path: map[node]int{}, memo: map[node]int{}, budget: budget}
+}
+
+// solve returns the value of the board for the side to move and the lowest
+// path depth of a repeated position the value depends on.
+func (r *reference) solve(turn, depth int) (int, int) {
+	c := r.core
+	current := node{turn: turn, position: c.key()}
+	if value, ok := r.memo[current]; ok {
+		return value, depth
+	}
+	if r.nodes++; r.nodes > r.budget {
+		panic(errBudget)
+	}
+	moves := []move.Move{}
+	c.moves(&moves, turn)
+	if len(moves) == 0 {
+		if c.config.EnableCheckmate && c.inCheck(turn) {
+			return -1, depth
+		}
+		return c.config.StaleMate.Value(), depth
+	}
+	r.path[current] = depth
+	defer delete(r.path, current)
+	res, dependsOn := -1, depth
+	for _, move := range moves {
+		if move.IsKing() {
+			res, dependsOn = 1, depth
+			break
+		}
+		promoted := c.promoted
+		what := c.applyMove(move)
+		next := node{turn: (turn + 1) % 2, position: c.key()}
+		value, nextDependsOn := 0, depth
+		if repeated, ok := r.path[next]; ok {
+			nextDependsOn = repeated
+		} else {
+			value, nextDependsOn = r.solve(next.turn, depth+1)
+			value = -value
+		}
+		c.undoMove(move, what, promoted)
+		dependsOn = min(dependsOn, nextDependsOn)
+		if value > res {
+			res = value
+		}
+		if res == 1 {
+			break
+		}
+	}
+	if res != 0 || dependsOn >= depth {
+		r.memo[current] = res
+	}
+	return res, dependsOn
+}
+
+// referenceValue returns the reference value of the config board, or false
+// if it exceeds the node budget.
+func referenceValue(t *testing.T, cfg config.Config, budget int) (res int, ok bool) {
+	t.Helper()
+	core, err := New(&bytes.Buffer{}, cfg)
+	if err != nil {
+		t.Fatalf("New %v got err %v", cfg, err)
+	}
+	defer func() {
+		if err := recover(); err != nil {
+			if err != errBudget {
+				panic(err)
+			}
+			ok = false
+		}
+	}()
+	res, _ = newReference(core, budget).solve(0, 0)
+	return res, true
+}
+
+func solveValue(t *testing.T, cfg config.Config) int {
+	t.Helper()
+	cfg.MaxPrintDepth = -1
+	core, err := New(&bytes.Buffer{}, cfg)
+	if err != nil {
+		t.Fatalf("New %v got err %v", cfg, err)
+	}
+	core.Solve()
+	value, _ := core.value(0)
+	return value
+}
+
+func TestReference(t *testing.T) {
+	configs := []config.Config{}
+	for _, in := range solveInputs {
+		configs = append(configs, in.config())
+	}
+	random := rand.New(rand.NewPCG(1, 2))
+	for range 100 {
+		configs = append(configs, randomConfig(random))
+	}
+	compared := 0
+	for _, cfg := range configs {
+		want, ok := referenceValue(t, cfg, 20000)
+		if !ok {
+			continue
+		}
+		compared++
+		for _, order := range moveOrders {
+			cfg.MoveOrder = order
+			if got := solveValue(t, cfg); got != want {
+				t.Errorf("Solve %q %s got %d want %d", cfg.Board, order, got, want)
+			}
+		}
+	}
+	t.Logf("compared %d of %d boards", compared, len(configs))
+	if compared < len(configs)/2 {
+		t.Errorf("Reference compared %d of %d boards", compared, len(configs))
+	}
+}
+
+// TestWant checks the wanted value of each board of TestSolve against the
+// retrograde solution of its enumerated state space.
+func TestWant(t *testing.T) {
+	for _, in := range solveInputs {
+		cfg := in.config()
+		core, err := New(&bytes.Buffer{}, cfg)
+		if err != nil {
+			t.Fatalf("New %v got err %v", cfg, err)
+		}
+		s := core.enumerate()
+		s.retrograde(cfg.StaleMate.Value())
+		if got := s.values[0]; got != in.want {
+			t.Errorf("Enumerate %s got %d want %d", in.name, got, in.want)
+		}
+	}
+}
+
+// randomConfig returns a random 3x3 board with both kings and up to two
+// other pieces.
+func randomConfig(random *rand.Rand) config.Config {
+	board := []byte(strings.Repeat(" ", 9))
+	for _, piece := range []byte("Kk") {
+		board[randomEmpty(random, board)] = piece
+	}
+	for range random.IntN(3) {
+		board[randomEmpty(random, board)] = "RBNPQrbnpq"[random.IntN(10)]
+	}
+	rows := []string{string(board[0:3]), string(board[3:6]), string(board[6:9])}
+	return config.Config{
+		Board: strings.Join(rows, ","), EnablePromotion: random.IntN(2) == 0, EnableDrop: random.IntN(2) == 0,
+		EnableCheckmate: random.IntN(4) == 0, StaleMate: config.Outcome(random.IntN(3)),
+	}
+}
+
+func randomEmpty(random *rand.Rand, board []byte) int {
+	for {
+		if i := random.IntN(len(board)); board[i] == ' ' {
+			return i
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package core
+
+import (
+	"iter"
+	"unsafe"
+
+	"github.com/kssilveira/chess-solver/config"
+)
+
+// Table stores the value and best move of the solved positions, indexed by
+// the player that just moved.
+type Table interface {
+	// Get returns the memo of the position.
+	Get(turn int, position Position) (Memo, bool)
+	// Put stores the memo of the position, solved at the search depth.
+	Put(turn int, position Position, memo Memo, depth int)
+	// Retain keeps only the memos for which keep returns true.
+	Retain(keep func(Memo) bool)
+	// Stored returns the number of stored memos, which is an upper bound on
+	// the number of positions for the tables that can keep a position more
+	// than once.
+	Stored() int
+	// Evicted returns the number of memos dropped to make room for others.
+	Evicted() int
+	// All returns the stored memos with the depth they were solved at, or 0
+	// for the tables that do not keep it.
+	All() iter.Seq[tableEntry]
+	// Close releases the files of the table.
+	Close() error
+}
+
+func newTable(config config.Config) (Table, error) {
+	switch {
+	case config.TableDir != "":
+		return newDiskTable(config.TableDir, config.TableSize)
+	case isBounded(config):
+		return newBoundedTable(config.TableSize), nil
+	}
+	return newMapTable(), nil
+}
+
+// isBounded returns whether the table drops memos to stay within its size.
+func isBounded(config config.Config) bool {
+	return config.TableSize > 0 && config.TableDir == ""
+}
+
+// mapTable keeps every memo.
+type mapTable [2]map[Position]Memo
+
+func newMapTable() *mapTable {
+	return &mapTable{make(map[Position]Memo, 100000), make(map[Position]Memo, 100000)}
+}
+
+func (t *mapTable) Get(turn int, position Position) (Memo, bool) {
+	memo, ok := t[turn][position]
+	return memo, ok
+}
+
+func (t *mapTable) Put(turn int, position Position, memo Memo, _ int) {
+	t[turn][position] = memo
+}
+
+func (t *mapTable) Retain(keep func(Memo) bool) {
+	for _, memos := range t {
+		for position, memo := range memos {
+			if !keep(memo) {
+				delete(memos, position)
+			}
+		}
+	}
+}
+
+func (t *mapTable) Stored() int {
+	return len(t[0]) + len(t[1])
+}
+
+func (t *mapTable) Evicted() int {
+	return 0
+}
+
+func (t *mapTable) All() iter.Seq[tableEntry] {
+	return func(yield func(tableEntry) bool) {
+		for turn, memos := range t {
+			for position, memo := range memos {
+				if !yield(tableEntry{position: position, memo: memo, turn: int8(turn), used: true}) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *mapTable) Close() error {
+	return nil
+}
+
+type tableEntry struct {
+	position Position
+	memo     Memo
+	depth    int32
+	turn     int8
+	used     bool
+}
+
+func (e *tableEntry) matches(turn int, position Position) bool {
+	return e.used && int(e.turn) == turn && e.position == position
+}
+
+// boundedTable keeps a fixed number of memos in buckets of two. A full bucket
+// keeps the new memo and the older one solved closest to the root, which took
+// the longest to search. The used entries of a bucket come first, so that
+// putting the memos again in the order of All restores the same buckets.
+type boundedTable struct {
+	buckets [][2]tableEntry
+	size    int
+	evicted int
+}
+
+func newBoundedTable(megabytes int) *boundedTable {
+	buckets := max(1, megabytes<<20/int(unsafe.Sizeof([2]tableEntry{})))
+	return &boundedTable{buckets: make([][2]tableEntry, buckets)}
+}
+
+func (t *boundedTable) bucket(turn int, position Position) *[2]tableEntry {
+	return &t.buckets[hash(turn, position)%uint64(len(t.buckets))]
+}
+
+func (t *boundedTable) Get(turn int, position Position) (Memo, bool) {
+	for _, entry := range t.bucket(turn, position) {
+		if entry.matches(turn, position) {
+			return entry.memo, true
+		}
+	}
+	return Memo{}, false
+}
+
+func (t *boundedTable) Put(turn int, position Position, memo Memo, depth int) {
+	bucket := t.bucket(turn, position)
+	entry := tableEntry{position: position, memo: memo, depth: int32(depth), turn: int8(turn), used: true}
+	for i := range bucket {
+		if bucket[i].matches(turn, position) {
+			entry.depth = min(entry.depth, bucket[i].depth)
+			bucket[i] = entry
+			return
+		}
+		if !bucket[i].used {
+			bucket[i] = entry
+			t.size++
+			return
+		}
+	}
+	// The new memo replaces the older one solved deeper, or the second one.
+	i := 1
+	if bucket[0].depth > bucket[1].depth {
+		i = 0
+	}
+	bucket[i] = entry
+	t.evicted++
+}
+
+func (t *boundedTable) Retain(keep func(Memo) bool) {
+	for i := range t.buckets {
+		bucket := &t.buckets[i]
+		kept := 0
+		for _, entry := range bucket {
+			if !entry.used {
+				continue
+			}
+			if !keep(entry.memo) {
+				t.size--
+				continue
+			}
+			bucket[kept] = entry
+			kept++
+		}
+		for j := kept; j < len(bucket); j++ {
+			bucket[j] = tableEntry{}
+		}
+	}
+}
+
+func (t *boundedTable) Stored() int {
+	return t.size
+}
+
+func (t *boundedTable) Evicted() int {
+	return t.evicted
+}
+
+func (t *boundedTable) All() iter.Seq[tableEntry] {
+	return func(yield func(tableEntry) bool) {
+		for _, bucket := range t.buckets {
+			for _, entry := range bucket {
+				if entry.used && !yield(entry) {
+					return
+				}
+			}
+		}
+	}
+}
+
+func (t *boundedTable) Close() error {
+	return nil
+}
+
+// hash returns the FNV-1a hash of the position, which unlike the map hash is
+// the same on every run, so that the evictions are deterministic.
+func hash(turn int, position Position) uint64 {
+	res := uint64(14695981039346656037)
+	add := func(v byte) {
+		res ^= uint64(v)
+		res *= 1099511628211
+	}
+	add(byte(turn))
+	for _, row := range position.Board {
+		for _, v := range row {
+			add(v)
+		}
+	}
+	for _, hand := range position.Hands {
+		for _, v := range hand {
+			add(v)
+		}
+	}
+	for i := range 8 {
+		add(byte(position.Promoted >> (8 * i)))
+	}
+	return res
+}
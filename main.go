@@ -2,7 +2,10 @@
 package main
 
 import (
+	"bufio"
 	"flag"
+	"io"
+	"log"
 	"os"
 	"time"
 
@@ -13,20 +16,64 @@ import (
 func main() {
 	sleepDuration := flag.Duration("sleep_duration", 0*time.Second, "sleep duration")
 	board := flag.String("board", "", "board")
+	width := flag.Int("width", 0, "board width (default: from board or 4)")
+	height := flag.Int("height", 0, "board height (default: from board or 4)")
+	rulesFile := flag.String("rules", "", "JSON piece rules file (default: tinyhouse)")
 	maxPrintDepth := flag.Int("max_print_depth", -1, "max depth")
 	enablePlay := flag.Bool("enable_play", false, "enable play")
+	enableShow := flag.Bool("enable_show", false, "enable show")
+	gifFile := flag.String("gif", "", "write shown game to animated GIF file")
+	gifDelay := flag.Duration("gif_delay", 1*time.Second, "GIF frame delay")
+	dotFile := flag.String("dot", "", "write solved game graph to Graphviz DOT file")
+	dotMaxDepth := flag.Int("dot_max_depth", 0, "DOT max depth")
+	dotBestOnly := flag.Bool("dot_best_only", false, "DOT only follows the winning side's chosen moves")
 	printDepth := flag.Bool("print_depth", true, "print depth")
 	enablePromotion := flag.Bool("enable_promotion", false, "enable promotion")
 	enableDrop := flag.Bool("enable_drop", false, "enable drop")
+	enableQueenPromotion := flag.Bool("enable_queen_promotion", false, "enable promotion to queen")
+	enableCheckmate := flag.Bool("enable_checkmate", false, "enable checkmate rules with legal moves only instead of king capture")
+	enableBlockedKnight := flag.Bool("enable_blocked_knight", false, "enable knight blocked by the adjacent orthogonal square")
+	staleMate := flag.String("stalemate", "draw", "stalemate outcome for the side to move: draw, win or loss")
+	enableDemotion := flag.Bool("enable_demotion", false, "enable captured promoted pieces going to hand as pawns")
+	perft := flag.Int("perft", 0, "print the number of move sequences of this depth from the board instead of searching")
+	moveOrder := flag.String("move_order", "static", "move order: static, history, killer or memo")
+	tableSize := flag.Int("table_mb", 0, "transposition table size in MB, replacing positions when full (default: unbounded)")
+	checkpointFile := flag.String("checkpoint", "", "periodically save the search to this file")
+	checkpointInterval := flag.Duration("checkpoint_interval", 10*time.Minute, "checkpoint interval")
+	resume := flag.Bool("resume", false, "resume the search from --checkpoint, saved with the same rules and board")
+	tableDir := flag.String("table_dir", "", "spill the transposition table to files in this directory, keeping --table_mb in memory")
 	runAll := flag.Bool("run_all", false, "run all")
+	enumerate := flag.Bool("enumerate", false, "enumerate and solve all reachable positions instead of searching")
+	enumerateFile := flag.String("enumerate_file", "", "write enumerated positions to file")
+	analyze := flag.Bool("analyze", false, "enumerate and print the value of every move from the board instead of searching")
+	explain := flag.String("explain", "", "enumerate and print the proof tree of the move from the board, such as K11-22")
+	explainMaxDepth := flag.Int("explain_max_depth", 0, "explain max depth")
+	explainDOTFile := flag.String("explain_dot", "", "write explained proof tree to Graphviz DOT file")
+	probeFile := flag.String("probe", "", "load the tablebase from this file and probe the boards given as arguments or on stdin, one per line")
+	puzzlesFile := flag.String("puzzles", "", "enumerate and write positions won by the side to move to puzzle file")
+	puzzleMoves := flag.Int("puzzle_moves", 0, "puzzle shortest forced win in moves of the winning side (default: any)")
+	puzzleUnique := flag.Bool("puzzle_unique", false, "puzzle has exactly one winning move")
+	puzzleMaterial := flag.String("puzzle_material", "", "puzzle board pieces in any order, such as KRk (default: any)")
+	puzzleHands := flag.String("puzzle_hands", "", "puzzle hands as in --board, such as 00010,00000 (default: any)")
 	flag.Parse()
+	staleMateOutcome, err := config.ParseOutcome(*staleMate)
+	if err != nil {
+		log.Fatal(err)
+	}
 	cfg := config.Config{
 		SleepDuration: *sleepDuration, MaxPrintDepth: *maxPrintDepth, PrintDepth: *printDepth,
-		EnablePromotion: *enablePromotion, EnableDrop: *enableDrop,
-		Board: *board,
+		EnablePromotion: *enablePromotion, EnableDrop: *enableDrop, EnableQueenPromotion: *enableQueenPromotion,
+		EnableDemotion: *enableDemotion, EnableCheckmate: *enableCheckmate, StaleMate: staleMateOutcome,
+		EnableBlockedKnight: *enableBlockedKnight,
+		EnableShow:          *enableShow || (*gifFile != "" && !*enablePlay), GIFDelay: *gifDelay,
+		DOTMaxDepth: *dotMaxDepth, DOTBestOnly: *dotBestOnly,
+		ExplainMaxDepth: *explainMaxDepth, MoveOrder: *moveOrder, TableSize: *tableSize, TableDir: *tableDir,
+		CheckpointFile: *checkpointFile, CheckpointInterval: *checkpointInterval,
+		PuzzleMoves: *puzzleMoves, PuzzleUnique: *puzzleUnique, PuzzleMaterial: *puzzleMaterial, PuzzleHands: *puzzleHands,
+		Board: *board, Width: *width, Height: *height, RulesFile: *rulesFile,
 	}
 	if *runAll {
-		core.RunAll(os.Stdout, []config.Config{
+		core.RunAll(os.Stdout, cfg, []config.Config{
 			{Board: "   k,    ,P   ,KR  "},
 			{Board: "   k,    ,P   ,KR  ", EnablePromotion: true},
 			{Board: "   k,    ,P   ,KR  ", EnableDrop: true},
@@ -39,9 +86,110 @@ func main() {
 		})
 		return
 	}
-	core := core.New(os.Stdout, cfg)
+	core, err := core.New(os.Stdout, cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// log.Fatal skips the deferred calls, so fatal closes the core first to
+	// remove the files of the table.
+	fatal := func(err error) {
+		core.Close()
+		log.Fatal(err)
+	}
+	defer func() {
+		if err := core.Close(); err != nil {
+			log.Print(err)
+		}
+	}()
+	// writeFile creates the file and checks the error of Close too, which
+	// can be the first to report a failed write.
+	writeFile := func(name string, write func(io.Writer) error) {
+		file, err := os.Create(name)
+		if err != nil {
+			fatal(err)
+		}
+		err = write(file)
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *resume {
+		file, err := os.Open(*checkpointFile)
+		if err != nil {
+			fatal(err)
+		}
+		err = core.Resume(file)
+		file.Close()
+		if err != nil {
+			fatal(err)
+		}
+	}
+	if *perft != 0 {
+		core.Perft(*perft)
+		return
+	}
+	if *probeFile != "" {
+		file, err := os.Open(*probeFile)
+		if err != nil {
+			fatal(err)
+		}
+		defer file.Close()
+		if err := core.LoadTablebase(file); err != nil {
+			fatal(err)
+		}
+		queries := flag.Args()
+		if len(queries) == 0 {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				queries = append(queries, scanner.Text())
+			}
+		}
+		for _, query := range queries {
+			if err := core.Probe(query); err != nil {
+				log.Print(err)
+			}
+		}
+		return
+	}
+	if *analyze {
+		core.Analyze()
+		return
+	}
+	if *explain != "" {
+		if err := core.Explain(*explain); err != nil {
+			fatal(err)
+		}
+		if *explainDOTFile != "" {
+			writeFile(*explainDOTFile, func(writer io.Writer) error {
+				return core.WriteExplainDOT(writer, *explain)
+			})
+		}
+		return
+	}
+	if *enumerate || *puzzlesFile != "" {
+		core.Enumerate()
+		if *enumerateFile != "" {
+			writeFile(*enumerateFile, core.WriteSpace)
+		}
+		if *puzzlesFile != "" {
+			writeFile(*puzzlesFile, core.WritePuzzles)
+		}
+		return
+	}
 	core.Solve()
+	if *dotFile != "" {
+		writeFile(*dotFile, func(writer io.Writer) error {
+			core.WriteDOT(writer)
+			return nil
+		})
+	}
 	if *enablePlay {
 		core.Play()
 	}
+	if *gifFile != "" {
+		writeFile(*gifFile, core.WriteGIF)
+	}
 }
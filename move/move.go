@@ -2,31 +2,31 @@
 package move
 
 // Move contains a move.
-type Move int16
+type Move int32
 
 // NewMove creates a new move.
 func NewMove(fx, fy, tx, ty int, isKing, isCapture bool) Move {
 	res := Move(0)
-	res |= (Move(fx) & 0b11) | ((Move(fy) & 0b11) << 2) | ((Move(tx) & 0b11) << 4) |
-		((Move(ty) & 0b11) << 6)
+	res |= (Move(fx) & 0b111) | ((Move(fy) & 0b111) << 3) | ((Move(tx) & 0b111) << 6) |
+		((Move(ty) & 0b111) << 9)
 	if isKing {
-		res |= 1 << 8
+		res |= 1 << 12
 	}
 	if isCapture {
-		res |= 1 << 9
+		res |= 1 << 13
 	}
 	return res
 }
 
 // SetPromotion sets promotion.
-func (m *Move) SetPromotion(promotion Move) {
-	*m &= ^(0b11 << 10)
-	*m |= (promotion & 0b11) << 10
+func (m *Move) SetPromotion(promotion int) {
+	*m &= ^(0b111 << 14)
+	*m |= (Move(promotion) & 0b111) << 14
 }
 
 // SetDrop sets drop.
 func (m *Move) SetDrop() {
-	*m |= 1 << 12
+	*m |= 1 << 17
 }
 
 // Get gets coordinates.
@@ -36,40 +36,40 @@ func (m Move) Get() (int, int, int, int) {
 
 // FromX returns from x.
 func (m Move) FromX() int {
-	return int(m & 0b11)
+	return int(m & 0b111)
 }
 
 // FromY returns from y.
 func (m Move) FromY() int {
-	return int((m & 0b1100) >> 2)
+	return int((m >> 3) & 0b111)
 }
 
 // ToX returns to x.
 func (m Move) ToX() int {
-	return int((m & 0b110000) >> 4)
+	return int((m >> 6) & 0b111)
 }
 
 // ToY returns to y.
 func (m Move) ToY() int {
-	return int((m & 0b11000000) >> 6)
+	return int((m >> 9) & 0b111)
 }
 
 // IsKing returns is king.
 func (m Move) IsKing() bool {
-	return m&(1<<8) != 0
+	return m&(1<<12) != 0
 }
 
 // IsCapture returns is capture.
 func (m Move) IsCapture() bool {
-	return m&(1<<9) != 0
+	return m&(1<<13) != 0
 }
 
 // Promotion returns promotion.
 func (m Move) Promotion() int {
-	return int((m & 0b110000000000) >> 10)
+	return int((m >> 14) & 0b111)
 }
 
 // IsDrop returns is drop.
 func (m Move) IsDrop() bool {
-	return (m&0b11000000000000)>>12 != 0
+	return m&(1<<17) != 0
 }
@@ -0,0 +1,144 @@
+// Package rules contains the piece movement rules.
+package rules
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+)
+
+//go:embed tinyhouse.json
+var tinyhouse []byte
+
+// Rules contains the piece movement rules.
+type Rules struct {
+	Pieces []Piece `json:"pieces"`
+}
+
+// Piece contains a piece definition, black pieces use the lowercase letter.
+// The queen promotions are only allowed with --enable_queen_promotion.
+type Piece struct {
+	Letter          string   `json:"letter"`
+	Moves           []Vector `json:"moves"`
+	Mirror          bool     `json:"mirror"`
+	Promotions      []string `json:"promotions"`
+	QueenPromotions []string `json:"queenPromotions"`
+	Hand            bool     `json:"hand"`
+	Royal           bool     `json:"royal"`
+}
+
+// Vector contains a movement vector from white's point of view.
+type Vector struct {
+	X           int   `json:"x"`
+	Y           int   `json:"y"`
+	Slide       bool  `json:"slide"`
+	MoveOnly    bool  `json:"moveOnly"`
+	CaptureOnly bool  `json:"captureOnly"`
+	Leg         []int `json:"leg"`
+}
+
+// Default returns the default tinyhouse rules.
+func Default() Rules {
+	res, err := Parse(tinyhouse)
+	if err != nil {
+		panic(err)
+	}
+	return res
+}
+
+// WithBlockedKnight returns the rules with the knight blocked by the adjacent orthogonal square.
+func (r Rules) WithBlockedKnight() Rules {
+	res := Rules{}
+	for _, piece := range r.Pieces {
+		if piece.Letter == "N" {
+			moves := []Vector{}
+			for _, vector := range piece.Moves {
+				vector.Leg = []int{sign(vector.X), 0}
+				if abs(vector.Y) > abs(vector.X) {
+					vector.Leg = []int{0, sign(vector.Y)}
+				}
+				moves = append(moves, vector)
+			}
+			piece.Moves = moves
+		}
+		res.Pieces = append(res.Pieces, piece)
+	}
+	return res
+}
+
+func sign(v int) int {
+	if v < 0 {
+		return -1
+	}
+	if v > 0 {
+		return 1
+	}
+	return 0
+}
+
+func abs(v int) int {
+	return max(v, -v)
+}
+
+// Load loads rules from a JSON file.
+func Load(path string) (Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Rules{}, err
+	}
+	return Parse(data)
+}
+
+// Parse parses JSON rules.
+func Parse(data []byte) (Rules, error) {
+	res := Rules{}
+	if err := json.Unmarshal(data, &res); err != nil {
+		return Rules{}, err
+	}
+	if err := res.validate(); err != nil {
+		return Rules{}, err
+	}
+	return res, nil
+}
+
+func (r Rules) validate() error {
+	letters := map[string]bool{}
+	for _, piece := range r.Pieces {
+		if len(piece.Letter) != 1 || piece.Letter[0] < 'A' || piece.Letter[0] > 'Z' {
+			return fmt.Errorf("piece letter %q must be one uppercase letter", piece.Letter)
+		}
+		if letters[piece.Letter] {
+			return fmt.Errorf("piece %q is defined twice", piece.Letter)
+		}
+		letters[piece.Letter] = true
+		for _, vector := range piece.Moves {
+			if vector.X == 0 && vector.Y == 0 {
+				return fmt.Errorf("piece %q has an empty vector", piece.Letter)
+			}
+			if vector.MoveOnly && vector.CaptureOnly {
+				return fmt.Errorf("piece %q has a vector that is both move only and capture only", piece.Letter)
+			}
+			if len(vector.Leg) != 0 && len(vector.Leg) != 2 {
+				return fmt.Errorf("piece %q has a leg %v that is not a pair", piece.Letter, vector.Leg)
+			}
+		}
+		if piece.Royal && piece.Hand {
+			return fmt.Errorf("royal piece %q cannot go to hand", piece.Letter)
+		}
+	}
+	targets := map[string]bool{}
+	for _, piece := range r.Pieces {
+		for _, promotion := range slices.Concat(piece.Promotions, piece.QueenPromotions) {
+			if !letters[promotion] {
+				return fmt.Errorf("piece %q promotes to undefined piece %q", piece.Letter, promotion)
+			}
+			if targets[promotion] {
+				return fmt.Errorf("piece %q is the promotion of more than one piece", promotion)
+			}
+			targets[promotion] = true
+		}
+	}
+	return nil
+}